@@ -1,12 +1,24 @@
 package main
 
 import (
+	"context"
+	"d2c-gs-controller/internal/admin"
 	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/leader"
 	"d2c-gs-controller/internal/monitor"
 	"d2c-gs-controller/internal/monitoring"
 	"d2c-gs-controller/internal/rabbit"
+	"d2c-gs-controller/internal/rcon"
 	"d2c-gs-controller/internal/redis"
+	"d2c-gs-controller/internal/util"
+	"d2c-gs-controller/internal/warmpool"
+	"errors"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/dota2classic/d2c-go-models/models"
 	"github.com/joho/godotenv"
@@ -27,20 +39,97 @@ func main() {
 		log.Println("No .env file found, relying on environment variables")
 	}
 
+	// Cancelled on SIGTERM/SIGINT: stops consumers, subscriptions and leader loops
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
 	db.ConnectAndMigrate()
-	rabbit.InitRabbit()
+	rabbit.InitRabbit(ctx)
 	redis.InitRedisClient()
 
-	go redis.Subscribe("KillServerRequestedEvent", func(msg *models.KillServerRequestedEvent) (*void, error) {
+	// Every replica hears a request, the one that claims it first runs it
+	go redis.SubscribeOnce(ctx, "KillServerRequestedEvent", time.Minute, func(msg *models.KillServerRequestedEvent) (*void, error) {
 		return nil, monitor.KillServer(msg.MatchID)
 	})
 
-	go monitor.CronMatchResourceStatus()
-	go monitor.CronServerHeartbeats()
+	go redis.SubscribeOnce(ctx, "ReplayParkedLaunchesRequestedEvent", time.Minute, func(msg *rabbit.ReplayParkedRequest) (*rabbit.ReplayParkedResponse, error) {
+		replayed, err := rabbit.Instance.ReplayParked(msg.Region, msg.Limit)
+		if err != nil {
+			return nil, err
+		}
+		return &rabbit.ReplayParkedResponse{Replayed: replayed}, nil
+	})
+
+	go redis.SubscribeOnce(ctx, "RconCommandRequestedEvent", time.Minute, func(msg *rcon.CommandRequest) (*rcon.CommandResponse, error) {
+		response, err := rcon.Execute(ctx, rcon.SourceRedis, msg)
+		res := &rcon.CommandResponse{MatchID: msg.MatchID, Command: msg.Command, Response: response}
+		if err != nil {
+			// Reply anyway, so the caller doesn't wait for a timeout
+			res.Error = err.Error()
+		}
+		return res, nil
+	})
+
+	// The website and the Discord bot ask for the connect and SourceTV addresses of a match
+	go redis.SubscribeOnce(ctx, "MatchServerAddressRequestedEvent", time.Minute, func(msg *monitor.ServerAddressRequest) (*monitor.ServerAddressResponse, error) {
+		return monitor.FindServerAddress(msg.MatchID)
+	})
+
+	// Reconcile, heartbeats, orphan sweeps, the launch queue and cleanup run on the leader only
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		leader.Run(ctx, func(ctx context.Context) {
+			go monitor.WatchMatchResources(ctx)
+			go monitor.CronServerHeartbeats(ctx)
+			go monitor.CronOrphanSweep(ctx)
+			go monitor.CronLaunchQueue(ctx)
+			go warmpool.CronRefill(ctx)
+			monitor.CronMatchResourceStatus(ctx)
+		})
+	}()
 
 	health := monitoring.NewHealthServer(redis.Client, rabbit.Instance.Conn)
-	log.Println("Starting server")
-	if health.Start(8080) != nil {
-		log.Fatal(err)
+	if token := os.Getenv("ADMIN_API_TOKEN"); token != "" {
+		health.Handle("/admin/", admin.NewAPI(token).Routes())
+	} else {
+		log.Println("ADMIN_API_TOKEN not set, admin API disabled")
 	}
+	go func() {
+		log.Println("Starting server")
+		if err := health.Start(8080); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down")
+
+	// Everything below shares one deadline, which has to stay under the pod's terminationGracePeriodSeconds
+	// (30s by default), or the kubelet kills the process while launches are still rolling back
+	deadline := time.Now().Add(util.GetEnvDuration("SHUTDOWN_TIMEOUT", "25s"))
+
+	httpDone := make(chan struct{})
+	go func() {
+		defer close(httpDone)
+		httpCtx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+		if err := health.Shutdown(httpCtx); err != nil {
+			log.Printf("Failed to stop health server: %v", err)
+		}
+	}()
+
+	rabbit.Instance.Shutdown(deadline)
+
+	select {
+	case <-leaderDone:
+	case <-time.After(time.Until(deadline)):
+		log.Println("Leader loops did not stop in time")
+	}
+	<-httpDone
+
+	redis.Close()
+	db.Close()
+	log.Println("Shutdown complete")
 }
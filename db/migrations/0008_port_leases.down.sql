@@ -0,0 +1 @@
+DROP TABLE IF EXISTS port_leases;
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS port_leases (
+    match_id BIGINT PRIMARY KEY,
+    region TEXT NOT NULL,
+    game_port INT NOT NULL,
+    tv_port INT NOT NULL,
+    leased_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (region, game_port),
+    UNIQUE (region, tv_port)
+);
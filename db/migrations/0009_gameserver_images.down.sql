@@ -0,0 +1,7 @@
+UPDATE gameserver_settings SET image = 'dota2classic/srcds:latest' WHERE image IS NULL;
+
+ALTER TABLE gameserver_settings
+    ALTER COLUMN image SET DEFAULT 'dota2classic/srcds:latest',
+    ALTER COLUMN image SET NOT NULL;
+
+DROP TABLE IF EXISTS gameserver_images;
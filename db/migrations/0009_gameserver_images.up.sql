@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS gameserver_images (
+    patch TEXT PRIMARY KEY,
+    image TEXT NOT NULL
+);
+
+-- 'default' is used when the patch has no image of its own
+INSERT INTO gameserver_images (patch, image) VALUES
+    ('default', 'dota2classic/srcds:d684-latest'),
+    ('DOTA_684', 'dota2classic/srcds:d684-latest'),
+    ('DOTA_684_TURBO', 'dota2classic/srcds:d684-turbo-latest'),
+    ('DOTA_688', 'dota2classic/srcds:d684-crash-fix-latest')
+ON CONFLICT (patch) DO NOTHING;
+
+-- gameserver_settings.image becomes an optional per-mode override
+ALTER TABLE gameserver_settings
+    ALTER COLUMN image DROP NOT NULL,
+    ALTER COLUMN image DROP DEFAULT;
+
+UPDATE gameserver_settings SET image = NULL WHERE image = 'dota2classic/srcds:latest';
@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS gameserver_region_settings;
+
+DELETE FROM gameserver_settings WHERE matchmaking_mode = -1;
+
+UPDATE gameserver_settings SET tickrate = 30 WHERE tickrate IS NULL;
+UPDATE gameserver_settings SET load_timeout = 90 WHERE load_timeout IS NULL;
+UPDATE gameserver_settings SET cpu_affinity = false WHERE cpu_affinity IS NULL;
+
+ALTER TABLE gameserver_settings
+    ALTER COLUMN tickrate SET NOT NULL,
+    ALTER COLUMN load_timeout SET DEFAULT 90,
+    ALTER COLUMN load_timeout SET NOT NULL,
+    ALTER COLUMN cpu_affinity SET DEFAULT false,
+    ALTER COLUMN cpu_affinity SET NOT NULL;
@@ -0,0 +1,20 @@
+-- NULL now means "inherit from the layer below"
+ALTER TABLE gameserver_settings
+    ALTER COLUMN tickrate DROP NOT NULL,
+    ALTER COLUMN load_timeout DROP NOT NULL,
+    ALTER COLUMN load_timeout DROP DEFAULT,
+    ALTER COLUMN cpu_affinity DROP NOT NULL,
+    ALTER COLUMN cpu_affinity DROP DEFAULT;
+
+-- matchmaking_mode -1 is the global default layer
+INSERT INTO gameserver_settings (matchmaking_mode, tickrate, load_timeout, cpu_affinity)
+VALUES (-1, 30, 90, false)
+ON CONFLICT (matchmaking_mode) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS gameserver_region_settings (
+    region TEXT PRIMARY KEY,
+    tickrate INT,
+    image TEXT,
+    load_timeout INT,
+    cpu_affinity BOOLEAN
+);
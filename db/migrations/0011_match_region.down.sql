@@ -0,0 +1,2 @@
+ALTER TABLE match_resources
+DROP COLUMN IF EXISTS region;
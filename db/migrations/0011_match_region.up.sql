@@ -0,0 +1,2 @@
+ALTER TABLE match_resources
+    ADD COLUMN region TEXT NOT NULL DEFAULT '';
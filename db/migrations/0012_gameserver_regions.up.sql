@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS gameserver_regions (
+    region TEXT PRIMARY KEY,
+    enabled BOOLEAN NOT NULL DEFAULT true
+);
+
+INSERT INTO gameserver_regions (region) VALUES
+    ('ru_moscow'),
+    ('ru_novosibirsk'),
+    ('eu_czech')
+ON CONFLICT (region) DO NOTHING;
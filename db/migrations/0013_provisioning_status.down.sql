@@ -0,0 +1,2 @@
+-- Postgres can't drop enum values; move rows off it instead
+UPDATE match_resources SET status = 'launching' WHERE status = 'provisioning';
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS orphan_sweeps (
+    id BIGSERIAL PRIMARY KEY,
+    match_id BIGINT,
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL,
+    action TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS orphan_sweeps_match_id_idx ON orphan_sweeps (match_id);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS match_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    match_id BIGINT NOT NULL,
+    region TEXT NOT NULL DEFAULT '',
+    from_status match_status NOT NULL,
+    to_status match_status NOT NULL,
+    node TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS match_status_history_match_idx ON match_status_history (match_id, changed_at);
+CREATE INDEX IF NOT EXISTS match_status_history_failed_idx ON match_status_history (region, changed_at) WHERE to_status = 'failed';
@@ -0,0 +1,3 @@
+DELETE FROM port_leases WHERE match_id < 0;
+DROP TABLE IF EXISTS warm_pool_members;
+DROP TABLE IF EXISTS warm_pool_settings;
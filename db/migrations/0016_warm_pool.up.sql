@@ -0,0 +1,22 @@
+-- Idle gameserver pods kept per region and patch; size 0 disables the pool
+CREATE TABLE IF NOT EXISTS warm_pool_settings (
+    region TEXT NOT NULL,
+    patch TEXT NOT NULL,
+    size INT NOT NULL DEFAULT 0 CHECK (size >= 0),
+    PRIMARY KEY (region, patch)
+);
+
+-- Warm servers hold their port lease under the negated id until a match claims them
+CREATE TABLE IF NOT EXISTS warm_pool_members (
+    id BIGSERIAL PRIMARY KEY,
+    region TEXT NOT NULL,
+    patch TEXT NOT NULL,
+    image TEXT NOT NULL,
+    job_name TEXT NOT NULL,
+    config_map_name TEXT NOT NULL,
+    secret_name TEXT NOT NULL,
+    ready BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS warm_pool_members_claim_idx ON warm_pool_members (region, image, created_at) WHERE ready;
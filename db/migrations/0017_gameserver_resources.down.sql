@@ -0,0 +1,10 @@
+ALTER TABLE gameserver_settings
+    DROP COLUMN IF EXISTS cpu_request,
+    DROP COLUMN IF EXISTS cpu_limit,
+    DROP COLUMN IF EXISTS memory_request,
+    DROP COLUMN IF EXISTS memory_limit,
+    DROP COLUMN IF EXISTS sidecar_cpu_request,
+    DROP COLUMN IF EXISTS sidecar_cpu_limit,
+    DROP COLUMN IF EXISTS sidecar_memory_request,
+    DROP COLUMN IF EXISTS sidecar_memory_limit,
+    DROP COLUMN IF EXISTS qos_class;
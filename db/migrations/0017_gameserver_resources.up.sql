@@ -0,0 +1,11 @@
+-- Kubernetes quantities like '450m' or '400Mi'; NULL inherits, and without any value the job template defaults apply
+ALTER TABLE gameserver_settings
+    ADD COLUMN IF NOT EXISTS cpu_request TEXT,
+    ADD COLUMN IF NOT EXISTS cpu_limit TEXT,
+    ADD COLUMN IF NOT EXISTS memory_request TEXT,
+    ADD COLUMN IF NOT EXISTS memory_limit TEXT,
+    ADD COLUMN IF NOT EXISTS sidecar_cpu_request TEXT,
+    ADD COLUMN IF NOT EXISTS sidecar_cpu_limit TEXT,
+    ADD COLUMN IF NOT EXISTS sidecar_memory_request TEXT,
+    ADD COLUMN IF NOT EXISTS sidecar_memory_limit TEXT,
+    ADD COLUMN IF NOT EXISTS qos_class TEXT CHECK (qos_class IN ('Guaranteed', 'Burstable', 'BestEffort'));
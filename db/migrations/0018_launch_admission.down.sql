@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS launch_queue;
+
+ALTER TABLE gameserver_settings
+    DROP COLUMN IF EXISTS launch_priority;
@@ -0,0 +1,16 @@
+-- Higher goes first when launches wait for capacity; NULL inherits like the other settings
+ALTER TABLE gameserver_settings
+    ADD COLUMN IF NOT EXISTS launch_priority INT;
+
+-- Launch commands held back because their region had no room. released_at is set when
+-- the command was put back on the exchange and is waiting to be consumed again.
+CREATE TABLE IF NOT EXISTS launch_queue (
+    match_id BIGINT PRIMARY KEY,
+    region TEXT NOT NULL,
+    priority INT NOT NULL DEFAULT 0,
+    command JSONB NOT NULL,
+    enqueued_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    released_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS launch_queue_order_idx ON launch_queue (region, priority DESC, enqueued_at);
@@ -0,0 +1,5 @@
+ALTER TABLE match_resources
+    DROP COLUMN IF EXISTS launch_command;
+
+DROP TABLE IF EXISTS match_failovers;
+DROP TABLE IF EXISTS failover_regions;
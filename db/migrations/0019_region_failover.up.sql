@@ -0,0 +1,28 @@
+-- Ordered fallback regions of a region per matchmaking mode. Mode -1 applies to modes without rows of their own.
+-- The latency penalty is what players of the region should expect on top of their usual ping.
+CREATE TABLE IF NOT EXISTS failover_regions (
+    matchmaking_mode INT NOT NULL,
+    region TEXT NOT NULL,
+    fallback_region TEXT NOT NULL CHECK (fallback_region <> region),
+    position INT NOT NULL DEFAULT 0,
+    latency_penalty_ms INT NOT NULL DEFAULT 0 CHECK (latency_penalty_ms >= 0),
+    PRIMARY KEY (matchmaking_mode, region, fallback_region)
+);
+
+-- Every move of a match to another region. Rows outlive match_resources.
+CREATE TABLE IF NOT EXISTS match_failovers (
+    id BIGSERIAL PRIMARY KEY,
+    match_id BIGINT NOT NULL,
+    requested_region TEXT NOT NULL,
+    from_region TEXT NOT NULL,
+    to_region TEXT NOT NULL,
+    latency_penalty_ms INT NOT NULL DEFAULT 0,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS match_failovers_match_idx ON match_failovers (match_id, id);
+
+-- The command a match was launched with, so reconcile can relaunch it in a fallback region
+ALTER TABLE match_resources
+    ADD COLUMN IF NOT EXISTS launch_command JSONB;
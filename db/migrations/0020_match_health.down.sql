@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS match_diagnostics;
+
+ALTER TABLE match_resources
+    DROP COLUMN IF EXISTS heartbeat_lost_at,
+    DROP COLUMN IF EXISTS unhealthy_at;
@@ -0,0 +1,17 @@
+-- Set while a running match has no fresh heartbeat; cleared when the heartbeat comes back
+ALTER TABLE match_resources
+    ADD COLUMN IF NOT EXISTS heartbeat_lost_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS unhealthy_at TIMESTAMP WITH TIME ZONE;
+
+-- Snapshots taken of silent matches and of servers beating for a match the controller doesn't know
+CREATE TABLE IF NOT EXISTS match_diagnostics (
+    id BIGSERIAL PRIMARY KEY,
+    match_id BIGINT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('silent', 'rogue')),
+    action TEXT NOT NULL CHECK (action IN ('reported', 'killed')),
+    reason TEXT NOT NULL DEFAULT '',
+    snapshot JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS match_diagnostics_match_idx ON match_diagnostics (match_id, created_at);
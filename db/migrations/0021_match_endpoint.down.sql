@@ -0,0 +1,5 @@
+ALTER TABLE match_resources
+    DROP COLUMN IF EXISTS node_name,
+    DROP COLUMN IF EXISTS host_ip,
+    DROP COLUMN IF EXISTS game_port,
+    DROP COLUMN IF EXISTS tv_port;
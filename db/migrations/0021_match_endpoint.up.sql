@@ -0,0 +1,6 @@
+-- Where the match is reachable, filled in by reconcile once the pod is scheduled
+ALTER TABLE match_resources
+    ADD COLUMN IF NOT EXISTS node_name TEXT,
+    ADD COLUMN IF NOT EXISTS host_ip TEXT,
+    ADD COLUMN IF NOT EXISTS game_port INT,
+    ADD COLUMN IF NOT EXISTS tv_port INT;
@@ -0,0 +1,14 @@
+-- Every RCON command sent through the controller, including the rejected ones
+CREATE TABLE IF NOT EXISTS rcon_audit (
+    id BIGSERIAL PRIMARY KEY,
+    match_id BIGINT NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL CHECK (source IN ('admin', 'redis')),
+    command TEXT NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    response TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS rcon_audit_match_idx ON rcon_audit (match_id, created_at);
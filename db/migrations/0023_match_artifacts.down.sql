@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS match_artifacts;
+
+ALTER TABLE match_resources
+    DROP COLUMN IF EXISTS artifacts_collected_at;
@@ -0,0 +1,13 @@
+-- Set once replays, logs and dumps were copied out of the pod; teardown waits for it
+ALTER TABLE match_resources
+    ADD COLUMN IF NOT EXISTS artifacts_collected_at TIMESTAMP WITH TIME ZONE;
+
+-- Objects uploaded per match; kept after the match_resources row is gone
+CREATE TABLE IF NOT EXISTS match_artifacts (
+    match_id BIGINT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('replay', 'log', 'dump')),
+    object_key TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (match_id, object_key)
+);
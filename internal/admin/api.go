@@ -0,0 +1,324 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/k8s"
+	"d2c-gs-controller/internal/monitor"
+	"d2c-gs-controller/internal/rabbit"
+	"d2c-gs-controller/internal/rcon"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+/**
+Admin API, mounted on the health server under /admin/.
+Every request needs "Authorization: Bearer <ADMIN_API_TOKEN>"; without a token the API isn't mounted at all.
+- GET    /admin/matches                active matches with status, age, node, ports and connect address
+- GET    /admin/matches/{id}           one match with job, pod and container states and its status timeline
+- DELETE /admin/matches/{id}           force-kill through monitor.KillServer
+- POST   /admin/matches/{id}/launch    re-publish a LaunchGameServerCommand; ?kill=true removes the current deployment first
+- GET    /admin/matches/{id}/artifacts object keys of the uploaded replays, logs and dumps, also after teardown
+- POST   /admin/matches/{id}/rcon      send {"command": ..., "actor": ...} over RCON, limited by RCON_ALLOWED_COMMANDS
+*/
+
+const matchIdLabel = "ru.dotaclassic/matchId"
+
+type API struct {
+	token string
+
+	// The handlers reach the database, cluster and broker only through these, so tests can run without them
+	findMatchResources func(matchId int64) (*db.MatchResources, error)
+	killServer         func(matchId int64) error
+	launchGameServer   func(cmd *models.LaunchGameServerCommand) error
+}
+
+func NewAPI(token string) *API {
+	return &API{
+		token:              token,
+		findMatchResources: db.FindMatchResources,
+		killServer:         monitor.KillServer,
+		launchGameServer:   rabbit.LaunchGameServer,
+	}
+}
+
+// Routes returns the authenticated handler for everything under /admin/
+func (a *API) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/matches", a.listMatches)
+	mux.HandleFunc("GET /admin/matches/{id}", a.getMatch)
+	mux.HandleFunc("DELETE /admin/matches/{id}", a.killMatch)
+	mux.HandleFunc("POST /admin/matches/{id}/launch", a.launchMatch)
+	mux.HandleFunc("GET /admin/matches/{id}/artifacts", a.listArtifacts)
+	mux.HandleFunc("POST /admin/matches/{id}/rcon", a.rconCommand)
+	return a.requireToken(mux)
+}
+
+func (a *API) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *API) listMatches(w http.ResponseWriter, r *http.Request) {
+	rows, err := db.FindAllMatchResources()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	leases, err := db.FindAllPortLeases()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// One list for all nodes instead of a call per match
+	nodes := map[string]string{}
+	pods, err := k8s.GetClient().CoreV1().Pods(k8s.Namespace).List(r.Context(), metav1.ListOptions{LabelSelector: matchIdLabel})
+	if err != nil {
+		log.Printf("[Admin] Failed to list pods: %v", err)
+	} else {
+		for _, pod := range pods.Items {
+			if pod.Spec.NodeName != "" {
+				nodes[pod.Labels[matchIdLabel]] = pod.Spec.NodeName
+			}
+		}
+	}
+
+	matches := make([]MatchSummary, 0, len(rows))
+	for i := range rows {
+		summary := newMatchSummary(&rows[i], leases[rows[i].MatchId])
+		if node, ok := nodes[strconv.FormatInt(rows[i].MatchId, 10)]; ok {
+			summary.Node = node
+		}
+		matches = append(matches, summary)
+	}
+
+	writeJSON(w, http.StatusOK, matches)
+}
+
+func (a *API) getMatch(w http.ResponseWriter, r *http.Request) {
+	matchId, ok := parseMatchId(w, r)
+	if !ok {
+		return
+	}
+
+	mr, ok := a.findMatch(w, matchId)
+	if !ok {
+		return
+	}
+
+	lease, _ := findLease(matchId)
+	details := MatchDetails{
+		MatchSummary:  newMatchSummary(mr, lease),
+		JobName:       mr.JobName,
+		ConfigMapName: mr.ConfigMapName,
+		SecretName:    mr.SecretName,
+		Pods:          []PodView{},
+	}
+
+	client := k8s.GetClient()
+	job, err := client.BatchV1().Jobs(k8s.Namespace).Get(r.Context(), mr.JobName, metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if err == nil {
+		details.Job = newJobView(job)
+	}
+
+	pods, err := client.CoreV1().Pods(k8s.Namespace).List(r.Context(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", mr.JobName),
+	})
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	for i := range pods.Items {
+		details.Pods = append(details.Pods, newPodView(&pods.Items[i]))
+		if details.Node == "" {
+			details.Node = pods.Items[i].Spec.NodeName
+		}
+	}
+
+	timeline, err := db.FindMatchTimeline(matchId)
+	if err != nil {
+		log.Printf("[Admin] Failed to load timeline of match %d: %v", matchId, err)
+	}
+	details.Timeline = newTimeline(timeline)
+
+	writeJSON(w, http.StatusOK, details)
+}
+
+func (a *API) killMatch(w http.ResponseWriter, r *http.Request) {
+	matchId, ok := parseMatchId(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.killServer(matchId)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "match not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("[Admin] Killed match %d", matchId)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"matchId": matchId, "killed": true})
+}
+
+func (a *API) launchMatch(w http.ResponseWriter, r *http.Request) {
+	matchId, ok := parseMatchId(w, r)
+	if !ok {
+		return
+	}
+
+	var cmd models.LaunchGameServerCommand
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid LaunchGameServerCommand: %v", err))
+		return
+	}
+	if cmd.MatchID == 0 {
+		cmd.MatchID = matchId
+	}
+	if cmd.MatchID != matchId {
+		writeError(w, http.StatusBadRequest, "matchId in body does not match the path")
+		return
+	}
+	if cmd.Region == "" {
+		writeError(w, http.StatusBadRequest, "region is required")
+		return
+	}
+
+	// The launch handler ignores commands for matches that are already deployed
+	_, err := a.findMatchResources(matchId)
+	if err == nil {
+		if r.URL.Query().Get("kill") != "true" {
+			writeError(w, http.StatusConflict, "match is deployed, kill it first or pass ?kill=true")
+			return
+		}
+		if err := a.killServer(matchId); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := a.launchGameServer(&cmd); err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	log.Printf("[Admin] Re-triggered launch of match %d in region %s", matchId, cmd.Region)
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"matchId": matchId, "region": cmd.Region, "queued": true})
+}
+
+func (a *API) listArtifacts(w http.ResponseWriter, r *http.Request) {
+	matchId, ok := parseMatchId(w, r)
+	if !ok {
+		return
+	}
+
+	artifacts, err := db.FindArtifacts(matchId)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, newArtifactViews(artifacts))
+}
+
+func (a *API) rconCommand(w http.ResponseWriter, r *http.Request) {
+	matchId, ok := parseMatchId(w, r)
+	if !ok {
+		return
+	}
+
+	var req rcon.CommandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid rcon request: %v", err))
+		return
+	}
+	req.MatchID = matchId
+	if req.Actor == "" {
+		req.Actor = "admin"
+	}
+
+	response, err := rcon.Execute(r.Context(), rcon.SourceAdmin, &req)
+	switch {
+	case errors.Is(err, rcon.ErrCommandNotAllowed):
+		writeError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		writeError(w, http.StatusNotFound, "match not found")
+	case errors.Is(err, rcon.ErrNotReachable):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusBadGateway, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, rcon.CommandResponse{MatchID: matchId, Command: req.Command, Response: response})
+	}
+}
+
+func parseMatchId(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	matchId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid match id")
+		return 0, false
+	}
+	return matchId, true
+}
+
+func (a *API) findMatch(w http.ResponseWriter, matchId int64) (*db.MatchResources, bool) {
+	mr, err := a.findMatchResources(matchId)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "match not found")
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return mr, true
+}
+
+func findLease(matchId int64) (db.PortLease, error) {
+	gsPort, tvPort, err := db.FindPortLease(matchId)
+	return db.PortLease{MatchId: matchId, GamePort: gsPort, TVPort: tvPort}, err
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[Admin] Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func ageSeconds(since time.Time) int64 {
+	return int64(time.Since(since).Seconds())
+}
@@ -0,0 +1,222 @@
+package admin
+
+import (
+	"d2c-gs-controller/internal/db"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+func TestRequireToken(t *testing.T) {
+	api := NewAPI("s3cret")
+	handler := api.requireToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	cases := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"no header", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"no bearer prefix", "s3cret", http.StatusUnauthorized},
+		{"valid token", "Bearer s3cret", http.StatusNoContent},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/admin/matches", nil)
+		if c.header != "" {
+			req.Header.Set("Authorization", c.header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != c.want {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.want, rec.Code)
+		}
+	}
+}
+
+// newTestAPI fakes every dependency of the handlers; a test replaces the ones it cares about
+func newTestAPI() *API {
+	api := NewAPI("s3cret")
+	api.findMatchResources = func(matchId int64) (*db.MatchResources, error) {
+		return nil, sql.ErrNoRows
+	}
+	api.killServer = func(matchId int64) error {
+		return sql.ErrNoRows
+	}
+	api.launchGameServer = func(cmd *models.LaunchGameServerCommand) error {
+		return nil
+	}
+	return api
+}
+
+func serve(api *API, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	api.Routes().ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not a JSON object: %q", rec.Body.String())
+	}
+	return body
+}
+
+func TestRouting(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		target string
+		want   int
+	}{
+		{"unknown path", http.MethodGet, "/admin/servers", http.StatusNotFound},
+		{"unknown sub-resource", http.MethodGet, "/admin/matches/7/logs", http.StatusNotFound},
+		{"wrong method on match", http.MethodPut, "/admin/matches/7", http.StatusMethodNotAllowed},
+		{"wrong method on launch", http.MethodGet, "/admin/matches/7/launch", http.StatusMethodNotAllowed},
+		{"get match", http.MethodGet, "/admin/matches/7", http.StatusNotFound},
+		{"kill match", http.MethodDelete, "/admin/matches/7", http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		if rec := serve(newTestAPI(), c.method, c.target, ""); rec.Code != c.want {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.want, rec.Code)
+		}
+	}
+}
+
+func TestInvalidMatchId(t *testing.T) {
+	cases := []struct {
+		method string
+		target string
+	}{
+		{http.MethodGet, "/admin/matches/abc"},
+		{http.MethodDelete, "/admin/matches/abc"},
+		{http.MethodPost, "/admin/matches/1.5/launch"},
+		{http.MethodGet, "/admin/matches/-/artifacts"},
+		{http.MethodPost, "/admin/matches/abc/rcon"},
+	}
+
+	for _, c := range cases {
+		rec := serve(newTestAPI(), c.method, c.target, "{}")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected status 400, got %d", c.method, c.target, rec.Code)
+			continue
+		}
+		if body := decodeBody(t, rec); body["error"] != "invalid match id" {
+			t.Errorf("%s %s: unexpected body %v", c.method, c.target, body)
+		}
+	}
+}
+
+func TestMatchNotFound(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		rec := serve(newTestAPI(), method, "/admin/matches/7", "")
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", method, rec.Code)
+			continue
+		}
+		if body := decodeBody(t, rec); body["error"] != "match not found" {
+			t.Errorf("%s: unexpected body %v", method, body)
+		}
+	}
+}
+
+func TestKillMatch(t *testing.T) {
+	api := newTestAPI()
+	var killed int64
+	api.killServer = func(matchId int64) error {
+		killed = matchId
+		return nil
+	}
+
+	rec := serve(api, http.MethodDelete, "/admin/matches/7", "")
+
+	if rec.Code != http.StatusOK || killed != 7 {
+		t.Fatalf("expected match 7 to be killed with 200, got %d and match %d", rec.Code, killed)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected a JSON response, got %q", ct)
+	}
+	body := decodeBody(t, rec)
+	if body["matchId"] != float64(7) || body["killed"] != true || len(body) != 2 {
+		t.Errorf("unexpected body %v", body)
+	}
+}
+
+func TestKillMatchError(t *testing.T) {
+	api := newTestAPI()
+	api.killServer = func(matchId int64) error {
+		return errors.New("database is down")
+	}
+
+	rec := serve(api, http.MethodDelete, "/admin/matches/7", "")
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", rec.Code)
+	}
+}
+
+func TestLaunchMatchDeployed(t *testing.T) {
+	var calls []string
+	api := newTestAPI()
+	api.findMatchResources = func(matchId int64) (*db.MatchResources, error) {
+		return &db.MatchResources{MatchId: matchId}, nil
+	}
+	api.killServer = func(matchId int64) error {
+		calls = append(calls, "kill")
+		return nil
+	}
+	api.launchGameServer = func(cmd *models.LaunchGameServerCommand) error {
+		calls = append(calls, fmt.Sprintf("launch %d in %s", cmd.MatchID, cmd.Region))
+		return nil
+	}
+
+	rec := serve(api, http.MethodPost, "/admin/matches/7/launch", `{"region":"ru_moscow"}`)
+	if rec.Code != http.StatusConflict || len(calls) != 0 {
+		t.Fatalf("expected a deployed match to be refused without ?kill=true, got %d and %v", rec.Code, calls)
+	}
+
+	rec = serve(api, http.MethodPost, "/admin/matches/7/launch?kill=true", `{"region":"ru_moscow"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(calls) != 2 || calls[0] != "kill" || calls[1] != "launch 7 in ru_moscow" {
+		t.Errorf("expected the kill before the launch, got %v", calls)
+	}
+	body := decodeBody(t, rec)
+	if body["matchId"] != float64(7) || body["region"] != "ru_moscow" || body["queued"] != true || len(body) != 3 {
+		t.Errorf("unexpected body %v", body)
+	}
+}
+
+func TestLaunchMatchInvalidCommand(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+	}{
+		{"not json", "region=ru_moscow"},
+		{"other match", `{"matchId":8,"region":"ru_moscow"}`},
+		{"no region", `{"matchId":7}`},
+	}
+
+	for _, c := range cases {
+		if rec := serve(newTestAPI(), http.MethodPost, "/admin/matches/7/launch", c.body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", c.name, rec.Code)
+		}
+	}
+}
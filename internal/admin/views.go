@@ -0,0 +1,200 @@
+package admin
+
+import (
+	"d2c-gs-controller/internal/db"
+	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type MatchSummary struct {
+	MatchID    int64         `json:"matchId"`
+	Region     models.Region `json:"region"`
+	Status     db.Status     `json:"status"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	AgeSeconds int64         `json:"ageSeconds"`
+	Node       string        `json:"node,omitempty"`
+	GamePort   int           `json:"gamePort,omitempty"`
+	TVPort     int           `json:"tvPort,omitempty"`
+	Connect    string        `json:"connect,omitempty"`  // ip:port once the pod is scheduled
+	SourceTV   string        `json:"sourceTv,omitempty"` // ip:port once the pod is scheduled
+}
+
+type MatchDetails struct {
+	MatchSummary
+	JobName       string           `json:"jobName"`
+	ConfigMapName string           `json:"configMapName"`
+	SecretName    string           `json:"secretName"`
+	Job           *JobView         `json:"job"` // nil once the job is gone
+	Pods          []PodView        `json:"pods"`
+	Timeline      []TransitionView `json:"timeline"`
+}
+
+type JobView struct {
+	Name       string          `json:"name"`
+	Active     int32           `json:"active"`
+	Succeeded  int32           `json:"succeeded"`
+	Failed     int32           `json:"failed"`
+	StartTime  *time.Time      `json:"startTime,omitempty"`
+	Conditions []ConditionView `json:"conditions"`
+}
+
+type PodView struct {
+	Name       string          `json:"name"`
+	Phase      corev1.PodPhase `json:"phase"`
+	Node       string          `json:"node,omitempty"`
+	HostIP     string          `json:"hostIp,omitempty"`
+	Reason     string          `json:"reason,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	Conditions []ConditionView `json:"conditions"`
+	Containers []ContainerView `json:"containers"`
+}
+
+type ContainerView struct {
+	Name         string `json:"name"`
+	Ready        bool   `json:"ready"`
+	RestartCount int32  `json:"restartCount"`
+	State        string `json:"state"` // waiting, running or terminated
+	Reason       string `json:"reason,omitempty"`
+	Message      string `json:"message,omitempty"`
+	ExitCode     *int32 `json:"exitCode,omitempty"`
+}
+
+type ConditionView struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type TransitionView struct {
+	From      db.Status `json:"from"`
+	To        db.Status `json:"to"`
+	Node      string    `json:"node,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+type ArtifactView struct {
+	Kind       db.ArtifactKind `json:"kind"`
+	ObjectKey  string          `json:"objectKey"`
+	SizeBytes  int64           `json:"sizeBytes"`
+	UploadedAt time.Time       `json:"uploadedAt"`
+}
+
+func newMatchSummary(mr *db.MatchResources, lease db.PortLease) MatchSummary {
+	summary := MatchSummary{
+		MatchID:    mr.MatchId,
+		Region:     mr.Region,
+		Status:     mr.Status,
+		CreatedAt:  mr.CreatedAt,
+		AgeSeconds: ageSeconds(mr.CreatedAt),
+		GamePort:   lease.GamePort,
+		TVPort:     lease.TVPort,
+		Node:       mr.Endpoint.Node,
+	}
+	if mr.Endpoint.Known() {
+		summary.Connect = mr.Endpoint.ConnectString()
+		summary.SourceTV = mr.Endpoint.SourceTVAddress()
+	}
+	return summary
+}
+
+func newJobView(job *batchv1.Job) *JobView {
+	view := &JobView{
+		Name:       job.Name,
+		Active:     job.Status.Active,
+		Succeeded:  job.Status.Succeeded,
+		Failed:     job.Status.Failed,
+		Conditions: []ConditionView{},
+	}
+	if job.Status.StartTime != nil {
+		view.StartTime = &job.Status.StartTime.Time
+	}
+	for _, cond := range job.Status.Conditions {
+		view.Conditions = append(view.Conditions, ConditionView{
+			Type:    string(cond.Type),
+			Status:  string(cond.Status),
+			Reason:  cond.Reason,
+			Message: cond.Message,
+		})
+	}
+	return view
+}
+
+func newPodView(pod *corev1.Pod) PodView {
+	view := PodView{
+		Name:       pod.Name,
+		Phase:      pod.Status.Phase,
+		Node:       pod.Spec.NodeName,
+		HostIP:     pod.Status.HostIP,
+		Reason:     pod.Status.Reason,
+		Message:    pod.Status.Message,
+		Conditions: []ConditionView{},
+		Containers: []ContainerView{},
+	}
+	for _, cond := range pod.Status.Conditions {
+		view.Conditions = append(view.Conditions, ConditionView{
+			Type:    string(cond.Type),
+			Status:  string(cond.Status),
+			Reason:  cond.Reason,
+			Message: cond.Message,
+		})
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		view.Containers = append(view.Containers, newContainerView(cs))
+	}
+	return view
+}
+
+func newContainerView(cs corev1.ContainerStatus) ContainerView {
+	view := ContainerView{
+		Name:         cs.Name,
+		Ready:        cs.Ready,
+		RestartCount: cs.RestartCount,
+	}
+
+	switch {
+	case cs.State.Running != nil:
+		view.State = "running"
+	case cs.State.Terminated != nil:
+		view.State = "terminated"
+		view.Reason = cs.State.Terminated.Reason
+		view.Message = cs.State.Terminated.Message
+		view.ExitCode = &cs.State.Terminated.ExitCode
+	case cs.State.Waiting != nil:
+		view.State = "waiting"
+		view.Reason = cs.State.Waiting.Reason
+		view.Message = cs.State.Waiting.Message
+	}
+	return view
+}
+
+func newTimeline(transitions []db.StatusTransition) []TransitionView {
+	timeline := make([]TransitionView, 0, len(transitions))
+	for _, t := range transitions {
+		timeline = append(timeline, TransitionView{
+			From:      t.From,
+			To:        t.To,
+			Node:      t.Node,
+			Reason:    t.Reason,
+			ChangedAt: t.ChangedAt,
+		})
+	}
+	return timeline
+}
+
+func newArtifactViews(artifacts []db.MatchArtifact) []ArtifactView {
+	views := make([]ArtifactView, 0, len(artifacts))
+	for _, a := range artifacts {
+		views = append(views, ArtifactView{
+			Kind:       a.Kind,
+			ObjectKey:  a.ObjectKey,
+			SizeBytes:  a.Size,
+			UploadedAt: a.CreatedAt,
+		})
+	}
+	return views
+}
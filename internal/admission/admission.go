@@ -0,0 +1,169 @@
+package admission
+
+import (
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/k8s"
+	"d2c-gs-controller/internal/metrics"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+/**
+Admission runs before a cold launch and compares the region capacity with what the match asks for.
+ADMISSION_MODE picks what happens to a launch that doesn't fit:
+- off: launch anyway, as before (default); only modes with fallback regions get their capacity checked
+- reject: fail the launch right away with a NoFreeServerEvent
+- queue: keep the command in launch_queue; the leader releases it by priority once there is room
+  and drops it with a NoFreeServerEvent after ADMISSION_QUEUE_TIMEOUT
+In every mode, a launch that doesn't fit fails over to a fallback region with room first if its mode has
+one (see failover.go).
+*/
+
+type Mode string
+
+const (
+	ModeOff    Mode = "off"
+	ModeReject Mode = "reject"
+	ModeQueue  Mode = "queue"
+)
+
+var ErrRegionFull = errors.New("region has no capacity left")
+
+func CurrentMode() Mode {
+	switch mode := Mode(os.Getenv("ADMISSION_MODE")); mode {
+	case "":
+		return ModeOff
+	case ModeOff, ModeReject, ModeQueue:
+		return mode
+	default:
+		log.Printf("Unknown ADMISSION_MODE %q, admission disabled", mode)
+		return ModeOff
+	}
+}
+
+// Admit decides whether the launch may go ahead now. A false without error means the command was queued.
+// A launch that doesn't fit may be moved to a fallback region, in which case evt.Region is updated.
+// Errors of the estimate itself let the launch through; reconcile catches it if it really doesn't fit.
+func Admit(evt *models.LaunchGameServerCommand) (bool, error) {
+	mode := CurrentMode()
+	if mode == ModeOff {
+		failOverIfFull(evt)
+		return true, nil
+	}
+
+	queued, err := db.FindQueuedLaunch(evt.MatchID)
+	if err == nil {
+		if queued.ReleasedAt == nil {
+			log.Printf("Match %d is already waiting for capacity in region %s", evt.MatchID, evt.Region)
+			return false, nil
+		}
+		// Released by the queue, which has already checked the capacity
+		if err := db.DeleteQueuedLaunch(evt.MatchID); err != nil {
+			return false, err
+		}
+		decided(evt.Region, "released")
+		return true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	settings, err := db.ResolveSettings(evt.LobbyType, evt.Region)
+	if err != nil {
+		return false, err
+	}
+
+	// Launches don't overtake the ones already waiting
+	var reason string
+	if mode == ModeQueue {
+		waiting, err := db.CountWaitingLaunches(evt.Region)
+		if err != nil {
+			return false, err
+		}
+		if waiting > 0 {
+			reason = fmt.Sprintf("%d launches are already waiting in region %s", waiting, evt.Region)
+		}
+	}
+
+	if reason == "" {
+		capacity, err := Capacity(evt.Region, settings)
+		if err != nil {
+			log.Printf("Failed to estimate capacity for match %d, launching anyway: %v", evt.MatchID, err)
+			return true, nil
+		}
+		if capacity.Fits() {
+			decided(evt.Region, "admitted")
+			return true, nil
+		}
+		reason = capacity.String()
+	}
+
+	if failOver(evt, reason) {
+		decided(evt.Region, "failover")
+		return true, nil
+	}
+
+	if mode == ModeReject {
+		decided(evt.Region, "rejected")
+		return false, fmt.Errorf("%w: %s", ErrRegionFull, reason)
+	}
+	return false, enqueue(evt, settings, reason)
+}
+
+// failOverIfFull is what is left of admission when it is off: a launch whose mode has fallbacks still
+// moves to one when its region is full. Anything else launches where it was asked to.
+func failOverIfFull(evt *models.LaunchGameServerCommand) {
+	_, targets, err := Fallbacks(evt.MatchID, evt.LobbyType, evt.Region)
+	if err != nil {
+		log.Printf("Failed to find fallback regions for match %d: %v", evt.MatchID, err)
+		return
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	settings, err := db.ResolveSettings(evt.LobbyType, evt.Region)
+	if err != nil {
+		log.Printf("Failed to resolve settings for match %d: %v", evt.MatchID, err)
+		return
+	}
+	capacity, err := Capacity(evt.Region, settings)
+	if err != nil {
+		log.Printf("Failed to estimate capacity for match %d, launching anyway: %v", evt.MatchID, err)
+		return
+	}
+	if capacity.Fits() {
+		return
+	}
+
+	if failOver(evt, capacity.String()) {
+		decided(evt.Region, "failover")
+	}
+}
+
+// Capacity estimates the room left in the region for a match with these settings
+func Capacity(region models.Region, settings *db.GameServerSettings) (*k8s.RegionCapacity, error) {
+	request, err := k8s.MatchRequests(settings)
+	if err != nil {
+		return nil, err
+	}
+	return k8s.FindRegionCapacity(region, request)
+}
+
+func enqueue(evt *models.LaunchGameServerCommand, settings *db.GameServerSettings, reason string) error {
+	log.Printf("Queueing match %d with priority %d: %s", evt.MatchID, settings.LaunchPriority, reason)
+	if err := db.EnqueueLaunch(evt, settings.LaunchPriority); err != nil {
+		return err
+	}
+	decided(evt.Region, "queued")
+	return nil
+}
+
+func decided(region models.Region, decision string) {
+	metrics.AdmissionDecisions.WithLabelValues(string(region), decision).Inc()
+}
@@ -0,0 +1,110 @@
+package admission
+
+import (
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/metrics"
+	"log"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+/**
+Failover: failover_regions lists ordered fallbacks of a region per matchmaking mode.
+- admission moves a launch that doesn't fit its region to the first fallback with room, also with ADMISSION_MODE=off
+- reconcile relaunches a match whose pod could not be scheduled in the next fallback
+Fallbacks always come from the list of the region the matchmaker asked for, and a match is never
+sent back to a region it was already tried in. match_failovers keeps every move.
+*/
+
+// Fallbacks returns the region the match was requested in and the fallbacks it wasn't tried in yet
+func Fallbacks(matchId int64, mode models.MatchmakingMode, region models.Region) (models.Region, []db.FailoverTarget, error) {
+	failovers, err := db.FindFailovers(matchId)
+	if err != nil {
+		return region, nil, err
+	}
+
+	requested := region
+	if len(failovers) > 0 {
+		requested = failovers[0].RequestedRegion
+	}
+
+	targets, err := db.FindFailoverTargets(mode, requested)
+	if err != nil {
+		return requested, nil, err
+	}
+	return requested, untried(requested, failovers, targets), nil
+}
+
+func untried(requested models.Region, failovers []db.Failover, targets []db.FailoverTarget) []db.FailoverTarget {
+	tried := map[models.Region]bool{requested: true}
+	for _, f := range failovers {
+		tried[f.FromRegion] = true
+		tried[f.ToRegion] = true
+	}
+
+	var left []db.FailoverTarget
+	for _, t := range targets {
+		if !tried[t.Region] {
+			left = append(left, t)
+		}
+	}
+	return left
+}
+
+// MoveTo records the move and points the command at the fallback region
+func MoveTo(evt *models.LaunchGameServerCommand, requested models.Region, target db.FailoverTarget, reason string) error {
+	err := db.RecordFailover(db.Failover{
+		MatchId:          evt.MatchID,
+		RequestedRegion:  requested,
+		FromRegion:       evt.Region,
+		ToRegion:         target.Region,
+		LatencyPenaltyMs: target.LatencyPenaltyMs,
+		Reason:           reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Match %d fails over from %s to %s (+%dms): %s", evt.MatchID, evt.Region, target.Region, target.LatencyPenaltyMs, reason)
+	metrics.Failovers.WithLabelValues(string(evt.Region), string(target.Region)).Inc()
+	evt.Region = target.Region
+	return nil
+}
+
+// failOver moves a launch that doesn't fit its region to the first fallback with room and nobody waiting
+func failOver(evt *models.LaunchGameServerCommand, reason string) bool {
+	requested, targets, err := Fallbacks(evt.MatchID, evt.LobbyType, evt.Region)
+	if err != nil {
+		log.Printf("Failed to find fallback regions for match %d: %v", evt.MatchID, err)
+		return false
+	}
+
+	for _, target := range targets {
+		waiting, err := db.CountWaitingLaunches(target.Region)
+		if err != nil || waiting > 0 {
+			continue
+		}
+
+		settings, err := db.ResolveSettings(evt.LobbyType, target.Region)
+		if err != nil {
+			log.Printf("Failed to resolve settings of fallback %s for match %d: %v", target.Region, evt.MatchID, err)
+			continue
+		}
+		capacity, err := Capacity(target.Region, settings)
+		if err != nil {
+			log.Printf("Failed to estimate capacity of fallback %s for match %d: %v", target.Region, evt.MatchID, err)
+			continue
+		}
+		if !capacity.Fits() {
+			log.Printf("Fallback of match %d is full too: %s", evt.MatchID, capacity)
+			continue
+		}
+
+		if err := MoveTo(evt, requested, target, reason); err != nil {
+			log.Printf("Failed to record failover of match %d: %v", evt.MatchID, err)
+			return false
+		}
+		return true
+	}
+	return false
+}
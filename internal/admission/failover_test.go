@@ -0,0 +1,33 @@
+package admission
+
+import (
+	"d2c-gs-controller/internal/db"
+	"testing"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+func TestUntriedFallbacks(t *testing.T) {
+	targets := []db.FailoverTarget{
+		{Region: models.REGION_RU_MOSCOW, LatencyPenaltyMs: 40},
+		{Region: "eu_frankfurt", LatencyPenaltyMs: 70},
+		{Region: "ru_ekaterinburg", LatencyPenaltyMs: 30},
+	}
+	failovers := []db.Failover{
+		{RequestedRegion: "ru_novosibirsk", FromRegion: "ru_novosibirsk", ToRegion: models.REGION_RU_MOSCOW},
+	}
+
+	left := untried("ru_novosibirsk", failovers, targets)
+
+	if len(left) != 2 || left[0].Region != "eu_frankfurt" || left[1].Region != "ru_ekaterinburg" {
+		t.Errorf("expected the fallbacks after Moscow in policy order, got %+v", left)
+	}
+}
+
+func TestUntriedFallbacksSkipsRequestedRegion(t *testing.T) {
+	targets := []db.FailoverTarget{{Region: models.REGION_RU_MOSCOW}}
+
+	if left := untried(models.REGION_RU_MOSCOW, nil, targets); len(left) != 0 {
+		t.Errorf("expected the requested region to be left out, got %+v", left)
+	}
+}
@@ -0,0 +1,147 @@
+package artifacts
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/k8s"
+	"d2c-gs-controller/internal/metrics"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// artifactDirs are the volume mounts of the artifacts container, relative to / as tar writes them
+var artifactDirs = map[string]db.ArtifactKind{
+	"artifacts/replays": db.ArtifactReplay,
+	"artifacts/logs":    db.ArtifactLog,
+	"artifacts/dumps":   db.ArtifactDump,
+}
+
+// Collect streams the artifact volumes out of the artifacts container of the pod with tar and uploads every file.
+// A failed upload doesn't stop the others; the error lists all of them.
+func Collect(ctx context.Context, pod *corev1.Pod, matchId int64) ([]db.MatchArtifact, error) {
+	stdout, writer := io.Pipe()
+	streamDone := make(chan error, 1)
+	go func() {
+		err := execInPod(ctx, pod, []string{"tar", "-cf", "-", "-C", "/", strings.TrimPrefix(k8s.ArtifactsDir, "/")}, writer)
+		_ = writer.CloseWithError(err)
+		streamDone <- err
+	}()
+
+	uploaded, uploadErr := uploadArchive(ctx, tar.NewReader(stdout), matchId)
+	// Drain whatever tar still writes, so the stream can finish
+	_, _ = io.Copy(io.Discard, stdout)
+
+	if err := <-streamDone; err != nil {
+		// tar also fails on files that change while being read; what was read is still uploaded
+		uploadErr = errors.Join(uploadErr, fmt.Errorf("tar in pod %s: %w", pod.Name, err))
+	}
+	return uploaded, uploadErr
+}
+
+// ReleaseGate lets the artifacts container exit, and with it the pod
+func ReleaseGate(ctx context.Context, pod *corev1.Pod) error {
+	return execInPod(ctx, pod, []string{"touch", k8s.ArtifactsGateFile}, io.Discard)
+}
+
+func execInPod(ctx context.Context, pod *corev1.Pod, command []string, stdout io.Writer) error {
+	req := k8s.GetClient().CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: k8s.ArtifactsContainer,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(k8s.GetConfig(), "POST", req.URL())
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: stdout, Stderr: &stderr})
+	if err != nil && stderr.Len() > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return err
+}
+
+func uploadArchive(ctx context.Context, archive *tar.Reader, matchId int64) ([]db.MatchArtifact, error) {
+	prefix := os.Getenv("ARTIFACTS_PREFIX")
+
+	var uploaded []db.MatchArtifact
+	var errs []error
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		kind, name, ok := classify(header.Name)
+		if !ok {
+			continue
+		}
+
+		artifact := db.MatchArtifact{
+			MatchId:   matchId,
+			Kind:      kind,
+			ObjectKey: objectKey(prefix, matchId, string(kind), name),
+			Size:      header.Size,
+		}
+		if err := upload(ctx, artifact.ObjectKey, archive, header.Size); err != nil {
+			metrics.ArtifactUploads.WithLabelValues(string(kind), "failed").Inc()
+			errs = append(errs, fmt.Errorf("uploading %s: %w", header.Name, err))
+			continue
+		}
+		metrics.ArtifactUploads.WithLabelValues(string(kind), "ok").Inc()
+
+		if err := db.RecordArtifact(artifact); err != nil {
+			log.Printf("Failed to record artifact %s of match %d: %v", artifact.ObjectKey, matchId, err)
+		}
+		uploaded = append(uploaded, artifact)
+	}
+	return uploaded, errors.Join(errs...)
+}
+
+// classify maps a path in the archive to its kind and the name below the artifact dir.
+// Everything in replays and logs is kept; of the dumps volume, mounted as /tmp in the gameserver, only crash dumps are.
+func classify(name string) (db.ArtifactKind, string, bool) {
+	name = strings.TrimPrefix(path.Clean(name), "/")
+	for dir, kind := range artifactDirs {
+		rel, ok := strings.CutPrefix(name, dir+"/")
+		if !ok || rel == "" {
+			continue
+		}
+		if kind == db.ArtifactDump && !isDump(rel) {
+			return "", "", false
+		}
+		return kind, rel, true
+	}
+	return "", "", false
+}
+
+func isDump(name string) bool {
+	base := path.Base(name)
+	return strings.HasSuffix(base, ".mdmp") || strings.HasSuffix(base, ".dmp") || strings.HasPrefix(base, "core")
+}
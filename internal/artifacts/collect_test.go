@@ -0,0 +1,40 @@
+package artifacts
+
+import (
+	"d2c-gs-controller/internal/db"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		path string
+		kind db.ArtifactKind
+		name string
+		ok   bool
+	}{
+		{"artifacts/replays/123.dem", db.ArtifactReplay, "123.dem", true},
+		{"/artifacts/logs/123.log", db.ArtifactLog, "123.log", true},
+		{"artifacts/logs/console/2026.log", db.ArtifactLog, "console/2026.log", true},
+		{"artifacts/dumps/crash_20261018.mdmp", db.ArtifactDump, "crash_20261018.mdmp", true},
+		{"artifacts/dumps/core.1234", db.ArtifactDump, "core.1234", true},
+		{"artifacts/dumps/source_engine_xyz.tmp", "", "", false},
+		{"artifacts/replays", "", "", false},
+		{"etc/passwd", "", "", false},
+	}
+
+	for _, c := range cases {
+		kind, name, ok := classify(c.path)
+		if ok != c.ok || kind != c.kind || name != c.name {
+			t.Errorf("%s: expected (%q, %q, %v), got (%q, %q, %v)", c.path, c.kind, c.name, c.ok, kind, name, ok)
+		}
+	}
+}
+
+func TestObjectKey(t *testing.T) {
+	if key := objectKey("", 42, "replay", "42.dem"); key != "matches/42/replay/42.dem" {
+		t.Errorf("unexpected default key %q", key)
+	}
+	if key := objectKey("prod/artifacts/", 42, "log", "42.log"); key != "prod/artifacts/42/log/42.log" {
+		t.Errorf("unexpected prefixed key %q", key)
+	}
+}
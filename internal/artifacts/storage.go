@@ -0,0 +1,62 @@
+package artifacts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+/**
+Artifacts are stored in S3-compatible storage, MinIO works for local runs:
+- ARTIFACTS_ENDPOINT    host[:port] of the storage, collection is off without it
+- ARTIFACTS_BUCKET      bucket, collection is off without it
+- ARTIFACTS_ACCESS_KEY, ARTIFACTS_SECRET_KEY
+- ARTIFACTS_USE_SSL     "false" for plain http, default true
+- ARTIFACTS_PREFIX      key prefix, default "matches"; keys are <prefix>/<matchId>/<kind>/<file>
+k8s.ArtifactsEnabled tells whether the first two are set.
+*/
+
+var (
+	storage     *minio.Client
+	storageErr  error
+	storageInit sync.Once
+)
+
+func getStorage() (*minio.Client, error) {
+	storageInit.Do(func() {
+		useSSL, err := strconv.ParseBool(os.Getenv("ARTIFACTS_USE_SSL"))
+		if err != nil {
+			useSSL = true
+		}
+		storage, storageErr = minio.New(os.Getenv("ARTIFACTS_ENDPOINT"), &minio.Options{
+			Creds:  credentials.NewStaticV4(os.Getenv("ARTIFACTS_ACCESS_KEY"), os.Getenv("ARTIFACTS_SECRET_KEY"), ""),
+			Secure: useSSL,
+		})
+	})
+	return storage, storageErr
+}
+
+func upload(ctx context.Context, key string, r io.Reader, size int64) error {
+	client, err := getStorage()
+	if err != nil {
+		return fmt.Errorf("artifact storage: %w", err)
+	}
+	_, err = client.PutObject(ctx, os.Getenv("ARTIFACTS_BUCKET"), key, r, size, minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+	})
+	return err
+}
+
+func objectKey(prefix string, matchId int64, kind, name string) string {
+	if prefix == "" {
+		prefix = "matches"
+	}
+	return path.Join(prefix, strconv.FormatInt(matchId, 10), kind, name)
+}
@@ -0,0 +1,56 @@
+package db
+
+import "time"
+
+type ArtifactKind string
+
+const (
+	ArtifactReplay ArtifactKind = "replay"
+	ArtifactLog    ArtifactKind = "log"
+	ArtifactDump   ArtifactKind = "dump"
+)
+
+type MatchArtifact struct {
+	MatchId   int64
+	Kind      ArtifactKind
+	ObjectKey string
+	Size      int64
+	CreatedAt time.Time
+}
+
+// RecordArtifact keeps the latest upload when the same object is collected twice
+func RecordArtifact(a MatchArtifact) error {
+	db := ConnectAndMigrate()
+	_, err := db.Exec(`
+		INSERT INTO match_artifacts (match_id, kind, object_key, size_bytes)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (match_id, object_key) DO UPDATE SET size_bytes = EXCLUDED.size_bytes, created_at = NOW()
+	`, a.MatchId, a.Kind, a.ObjectKey, a.Size)
+	return err
+}
+
+func FindArtifacts(matchId int64) ([]MatchArtifact, error) {
+	db := ConnectAndMigrate()
+	rows, err := db.Query(`SELECT match_id, kind, object_key, size_bytes, created_at FROM match_artifacts WHERE match_id = $1 ORDER BY kind, object_key`, matchId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var artifacts []MatchArtifact
+	for rows.Next() {
+		var a MatchArtifact
+		if err := rows.Scan(&a.MatchId, &a.Kind, &a.ObjectKey, &a.Size, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, a)
+	}
+	return artifacts, rows.Err()
+}
+
+// MarkArtifactsCollected lets teardown go ahead, whether or not anything was uploaded
+func MarkArtifactsCollected(matchId int64) error {
+	db := ConnectAndMigrate()
+	_, err := db.Exec(`UPDATE match_resources SET artifacts_collected_at = NOW() WHERE match_id = $1 AND artifacts_collected_at IS NULL`, matchId)
+	return err
+}
@@ -8,15 +8,17 @@ import (
 
 func InsertMatchResources(mr MatchResources) error {
 	db := ConnectAndMigrate()
-	_, err := db.Exec(`INSERT INTO match_resources (match_id, job_name, secret_name, config_map_name) VALUES ($1, $2, $3, $4)`, mr.MatchId, mr.JobName, mr.SecretName, mr.ConfigMapName)
+	_, err := db.Exec(`INSERT INTO match_resources (match_id, job_name, secret_name, config_map_name, region, status) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (match_id) DO NOTHING`, mr.MatchId, mr.JobName, mr.SecretName, mr.ConfigMapName, mr.Region, mr.Status)
 	return err
 }
 
 func FindMatchResources(id int64) (*MatchResources, error) {
 	db := ConnectAndMigrate()
-	row := db.QueryRow(`SELECT match_id, job_name, secret_name, config_map_name, created_at, status FROM match_resources WHERE match_id=$1`, id)
+	row := db.QueryRow(`SELECT match_id, job_name, secret_name, config_map_name, created_at, status, region, heartbeat_lost_at, unhealthy_at,
+            COALESCE(node_name, ''), COALESCE(host_ip, ''), COALESCE(game_port, 0), COALESCE(tv_port, 0), artifacts_collected_at FROM match_resources WHERE match_id=$1`, id)
 	var mr MatchResources
-	if err := row.Scan(&mr.MatchId, &mr.JobName, &mr.SecretName, &mr.ConfigMapName, &mr.CreatedAt, &mr.Status); err != nil {
+	if err := row.Scan(&mr.MatchId, &mr.JobName, &mr.SecretName, &mr.ConfigMapName, &mr.CreatedAt, &mr.Status, &mr.Region, &mr.HeartbeatLostAt, &mr.UnhealthyAt,
+		&mr.Endpoint.Node, &mr.Endpoint.HostIP, &mr.Endpoint.GamePort, &mr.Endpoint.TVPort, &mr.ArtifactsCollectedAt); err != nil {
 		return nil, err
 	}
 	return &mr, nil
@@ -25,7 +27,8 @@ func FindMatchResources(id int64) (*MatchResources, error) {
 func FindAllMatchResources() ([]MatchResources, error) {
 	db := ConnectAndMigrate()
 	rows, err := db.Query(`
-        SELECT match_id, job_name, secret_name, config_map_name, created_at, status
+        SELECT match_id, job_name, secret_name, config_map_name, created_at, status, region, heartbeat_lost_at, unhealthy_at,
+            COALESCE(node_name, ''), COALESCE(host_ip, ''), COALESCE(game_port, 0), COALESCE(tv_port, 0), artifacts_collected_at
         FROM match_resources
     `)
 	if err != nil {
@@ -37,7 +40,8 @@ func FindAllMatchResources() ([]MatchResources, error) {
 
 	for rows.Next() {
 		var mr MatchResources
-		if err := rows.Scan(&mr.MatchId, &mr.JobName, &mr.SecretName, &mr.ConfigMapName, &mr.CreatedAt, &mr.Status); err != nil {
+		if err := rows.Scan(&mr.MatchId, &mr.JobName, &mr.SecretName, &mr.ConfigMapName, &mr.CreatedAt, &mr.Status, &mr.Region, &mr.HeartbeatLostAt, &mr.UnhealthyAt,
+			&mr.Endpoint.Node, &mr.Endpoint.HostIP, &mr.Endpoint.GamePort, &mr.Endpoint.TVPort, &mr.ArtifactsCollectedAt); err != nil {
 			log.Printf("Failed to scan row: %v", err)
 			continue
 		}
@@ -51,32 +55,42 @@ func FindAllMatchResources() ([]MatchResources, error) {
 	return resources, nil
 }
 
-func UpdateStatus(matchId int64, status Status) error {
+func DeleteMatchResources(matchId int64) {
 	db := ConnectAndMigrate()
-	rows, err := db.Query("UPDATE match_resources SET status = $1 WHERE match_id=$2", status, matchId)
+	rows, err := db.Query("DELETE FROM match_resources WHERE match_id=$1", matchId)
 	if err != nil {
-		log.Printf("Failed to update status: %v", err)
-		return err
+		log.Printf("Failed to delete resources: %v", err)
 	}
 	defer rows.Close()
-	return nil
 }
 
-func DeleteMatchResources(matchId int64) {
+// FindImageForPatch returns the catalog image for the patch, falling back to the 'default' row.
+func FindImageForPatch(patch models.DotaPatch) (string, error) {
 	db := ConnectAndMigrate()
-	rows, err := db.Query("DELETE FROM match_resources WHERE match_id=$1", matchId)
-	if err != nil {
-		log.Printf("Failed to delete resources: %v", err)
+	row := db.QueryRow(`SELECT image FROM gameserver_images WHERE patch IN ($1, $2) ORDER BY patch = $2 LIMIT 1`, patch, DefaultImagePatch)
+	var image string
+	if err := row.Scan(&image); err != nil {
+		return "", err
 	}
-	defer rows.Close()
+	return image, nil
 }
 
-func GetSettingsForMode(mode models.MatchmakingMode) (*GameServerSettings, error) {
+func FindEnabledRegions() ([]models.Region, error) {
 	db := ConnectAndMigrate()
-	row := db.QueryRow(`SELECT matchmaking_mode, tickrate, image, load_timeout, cpu_affinity FROM gameserver_settings WHERE matchmaking_mode=$1`, mode)
-	var gss GameServerSettings
-	if err := row.Scan(&gss.MatchmakingMode, &gss.TickRate, &gss.Image, &gss.LoadTimeout, &gss.CpuAffinity); err != nil {
+	rows, err := db.Query(`SELECT region FROM gameserver_regions WHERE enabled ORDER BY region`)
+	if err != nil {
 		return nil, err
 	}
-	return &gss, nil
+	defer rows.Close()
+
+	var regions []models.Region
+	for rows.Next() {
+		var region models.Region
+		if err := rows.Scan(&region); err != nil {
+			return nil, err
+		}
+		regions = append(regions, region)
+	}
+
+	return regions, rows.Err()
 }
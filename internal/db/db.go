@@ -49,6 +49,17 @@ func ConnectAndMigrate() *sql.DB {
 	return db
 }
 
+func Close() {
+	if db == nil {
+		return
+	}
+	if err := db.Close(); err != nil {
+		log.Printf("Failed to close db: %v", err)
+		return
+	}
+	log.Println("Database connection closed")
+}
+
 func runMigrations(db *sql.DB) {
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
 	if err != nil {
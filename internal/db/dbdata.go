@@ -4,12 +4,17 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
 )
 
 // Status maps to Postgres match_status enum
 type Status string
 
 const (
+	// StatusProvisioning: row written, Kubernetes objects still being created
+	StatusProvisioning Status = "provisioning"
+
 	StatusPending   Status = "pending"
 	StatusLaunching Status = "launching"
 	StatusRunning   Status = "running"
@@ -43,12 +48,38 @@ type MatchResources struct {
 	ConfigMapName string
 	CreatedAt     time.Time
 	Status        Status
+	Region        models.Region
+
+	HeartbeatLostAt *time.Time // running without a fresh heartbeat since
+	UnhealthyAt     *time.Time // silent past the grace period since
+
+	Endpoint MatchEndpoint // empty until the pod is scheduled
+
+	ArtifactsCollectedAt *time.Time // replays, logs and dumps copied out, teardown may go ahead
 }
 
+// DefaultImagePatch is the gameserver_images key used when a patch has no image of its own
+const DefaultImagePatch = "default"
+
 type GameServerSettings struct {
 	MatchmakingMode int64
 	TickRate        int
-	Image           string
+	Image           string // mode or region override, empty when the patch catalog should be used
 	LoadTimeout     int
 	CpuAffinity     bool
+
+	// Empty fields keep the defaults of the job template
+	Resources        ContainerResources
+	SidecarResources ContainerResources
+	QosClass         string // Guaranteed, Burstable, BestEffort; empty takes whatever the resources give
+
+	LaunchPriority int // order of launches waiting for capacity, higher goes first
+}
+
+// ContainerResources holds Kubernetes quantities as configured, e.g. "450m" or "400Mi"
+type ContainerResources struct {
+	CpuRequest    string
+	CpuLimit      string
+	MemoryRequest string
+	MemoryLimit   string
 }
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"net"
+	"strconv"
+)
+
+// MatchEndpoint is where players and SourceTV viewers reach the match
+type MatchEndpoint struct {
+	Node     string
+	HostIP   string
+	GamePort int
+	TVPort   int
+}
+
+func (e MatchEndpoint) Known() bool {
+	return e.HostIP != "" && e.GamePort != 0
+}
+
+// ConnectString is the ip:port players connect to
+func (e MatchEndpoint) ConnectString() string {
+	return net.JoinHostPort(e.HostIP, strconv.Itoa(e.GamePort))
+}
+
+// SourceTVAddress is empty when the match has no SourceTV port
+func (e MatchEndpoint) SourceTVAddress() string {
+	if e.TVPort == 0 {
+		return ""
+	}
+	return net.JoinHostPort(e.HostIP, strconv.Itoa(e.TVPort))
+}
+
+// SetMatchEndpoint stores where the match runs. It returns false when nothing changed or the match is gone.
+func SetMatchEndpoint(matchId int64, e MatchEndpoint) (bool, error) {
+	db := ConnectAndMigrate()
+	res, err := db.Exec(`
+		UPDATE match_resources
+		SET node_name = $2, host_ip = $3, game_port = $4, tv_port = NULLIF($5, 0)
+		WHERE match_id = $1
+		  AND (node_name, host_ip, game_port, COALESCE(tv_port, 0)) IS DISTINCT FROM ($2, $3, $4, $5)
+	`, matchId, e.Node, e.HostIP, e.GamePort, e.TVPort)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,28 @@
+package db
+
+import "testing"
+
+func TestMatchEndpointAddresses(t *testing.T) {
+	cases := []struct {
+		endpoint MatchEndpoint
+		connect  string
+		tv       string
+	}{
+		{MatchEndpoint{HostIP: "10.0.0.5", GamePort: 30100, TVPort: 30101}, "10.0.0.5:30100", "10.0.0.5:30101"},
+		{MatchEndpoint{HostIP: "10.0.0.5", GamePort: 30100}, "10.0.0.5:30100", ""},
+		{MatchEndpoint{HostIP: "2001:db8::1", GamePort: 30100, TVPort: 30101}, "[2001:db8::1]:30100", "[2001:db8::1]:30101"},
+	}
+
+	for _, c := range cases {
+		if got := c.endpoint.ConnectString(); got != c.connect {
+			t.Errorf("expected connect string %q, got %q", c.connect, got)
+		}
+		if got := c.endpoint.SourceTVAddress(); got != c.tv {
+			t.Errorf("expected SourceTV address %q, got %q", c.tv, got)
+		}
+	}
+
+	if (MatchEndpoint{GamePort: 30100}).Known() {
+		t.Error("endpoint without host ip should not be known")
+	}
+}
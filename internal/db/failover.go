@@ -0,0 +1,136 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+// FailoverTarget is one entry of a region's fallback list
+type FailoverTarget struct {
+	Region           models.Region
+	LatencyPenaltyMs int
+}
+
+// Failover is a move of a match from one region to another
+type Failover struct {
+	MatchId          int64
+	RequestedRegion  models.Region // what the matchmaker asked for
+	FromRegion       models.Region
+	ToRegion         models.Region
+	LatencyPenaltyMs int
+	Reason           string
+	CreatedAt        time.Time
+}
+
+// FindFailoverTargets returns the enabled fallbacks of the region in order. Rows of the mode replace the
+// default ones (mode -1) as a whole, so a mode can also narrow the default list down.
+func FindFailoverTargets(mode models.MatchmakingMode, region models.Region) ([]FailoverTarget, error) {
+	db := ConnectAndMigrate()
+	rows, err := db.Query(`
+		WITH policy AS (
+			SELECT f.matchmaking_mode, f.fallback_region, f.position, f.latency_penalty_ms
+			FROM failover_regions f
+			JOIN gameserver_regions r ON r.region = f.fallback_region
+			WHERE f.region = $1 AND f.matchmaking_mode IN ($2, $3) AND r.enabled
+		)
+		SELECT fallback_region, latency_penalty_ms
+		FROM policy
+		WHERE matchmaking_mode = (SELECT MAX(matchmaking_mode) FROM policy)
+		ORDER BY position, latency_penalty_ms
+	`, region, mode, DefaultSettingsMode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var targets []FailoverTarget
+	for rows.Next() {
+		var t FailoverTarget
+		if err := rows.Scan(&t.Region, &t.LatencyPenaltyMs); err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	return targets, rows.Err()
+}
+
+// FindFailovers returns the moves of the match, oldest first
+func FindFailovers(matchId int64) ([]Failover, error) {
+	db := ConnectAndMigrate()
+	rows, err := db.Query(`
+		SELECT match_id, requested_region, from_region, to_region, latency_penalty_ms, reason, created_at
+		FROM match_failovers
+		WHERE match_id = $1
+		ORDER BY id
+	`, matchId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var failovers []Failover
+	for rows.Next() {
+		var f Failover
+		if err := rows.Scan(&f.MatchId, &f.RequestedRegion, &f.FromRegion, &f.ToRegion, &f.LatencyPenaltyMs, &f.Reason, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		failovers = append(failovers, f)
+	}
+	return failovers, rows.Err()
+}
+
+// FindLastFailover returns the latest move of the match, or sql.ErrNoRows if it stayed where it was requested
+func FindLastFailover(matchId int64) (*Failover, error) {
+	db := ConnectAndMigrate()
+	var f Failover
+	err := db.QueryRow(`
+		SELECT match_id, requested_region, from_region, to_region, latency_penalty_ms, reason, created_at
+		FROM match_failovers
+		WHERE match_id = $1
+		ORDER BY id DESC
+		LIMIT 1
+	`, matchId).Scan(&f.MatchId, &f.RequestedRegion, &f.FromRegion, &f.ToRegion, &f.LatencyPenaltyMs, &f.Reason, &f.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func RecordFailover(f Failover) error {
+	db := ConnectAndMigrate()
+	_, err := db.Exec(`
+		INSERT INTO match_failovers (match_id, requested_region, from_region, to_region, latency_penalty_ms, reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, f.MatchId, f.RequestedRegion, f.FromRegion, f.ToRegion, f.LatencyPenaltyMs, f.Reason)
+	return err
+}
+
+// SaveLaunchCommand keeps the command next to the match for relaunches
+func SaveLaunchCommand(cmd *models.LaunchGameServerCommand) error {
+	command, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+
+	db := ConnectAndMigrate()
+	_, err = db.Exec(`UPDATE match_resources SET launch_command = $1 WHERE match_id = $2`, command, cmd.MatchID)
+	return err
+}
+
+// FindLaunchCommand returns sql.ErrNoRows when the match has no row or was launched before commands were kept
+func FindLaunchCommand(matchId int64) (*models.LaunchGameServerCommand, error) {
+	db := ConnectAndMigrate()
+	var command []byte
+	err := db.QueryRow(`SELECT launch_command FROM match_resources WHERE match_id = $1 AND launch_command IS NOT NULL`, matchId).Scan(&command)
+	if err != nil {
+		return nil, err
+	}
+
+	var cmd models.LaunchGameServerCommand
+	if err := json.Unmarshal(command, &cmd); err != nil {
+		return nil, err
+	}
+	return &cmd, nil
+}
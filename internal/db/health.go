@@ -0,0 +1,53 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+)
+
+type DiagnosticKind string
+
+const (
+	DiagnosticSilent DiagnosticKind = "silent" // running in Kubernetes, no heartbeat
+	DiagnosticRogue  DiagnosticKind = "rogue"  // heartbeat for a match without a row
+)
+
+type MatchDiagnostic struct {
+	MatchId  int64
+	Kind     DiagnosticKind
+	Action   string // reported or killed
+	Reason   string
+	Snapshot []byte // JSON
+}
+
+// MarkHeartbeatLost starts the grace period of a running match that stopped beating
+func MarkHeartbeatLost(matchId int64) error {
+	db := ConnectAndMigrate()
+	_, err := db.Exec(`UPDATE match_resources SET heartbeat_lost_at = NOW() WHERE match_id = $1 AND heartbeat_lost_at IS NULL`, matchId)
+	return err
+}
+
+// MarkUnhealthy is a compare-and-set: it returns false when the match was already marked or is gone
+func MarkUnhealthy(matchId int64) (bool, error) {
+	db := ConnectAndMigrate()
+	var id int64
+	err := db.QueryRow(`UPDATE match_resources SET unhealthy_at = NOW() WHERE match_id = $1 AND unhealthy_at IS NULL RETURNING match_id`, matchId).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ClearHeartbeatLoss marks the match healthy again once its heartbeat is back
+func ClearHeartbeatLoss(matchId int64) error {
+	db := ConnectAndMigrate()
+	_, err := db.Exec(`UPDATE match_resources SET heartbeat_lost_at = NULL, unhealthy_at = NULL WHERE match_id = $1`, matchId)
+	return err
+}
+
+func RecordDiagnostic(d MatchDiagnostic) error {
+	db := ConnectAndMigrate()
+	_, err := db.Exec(`INSERT INTO match_diagnostics (match_id, kind, action, reason, snapshot) VALUES ($1, $2, $3, $4, $5)`,
+		d.MatchId, d.Kind, d.Action, d.Reason, d.Snapshot)
+	return err
+}
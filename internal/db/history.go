@@ -0,0 +1,92 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+// StatusTransition is one row of match_status_history. Rows outlive match_resources.
+type StatusTransition struct {
+	MatchId   int64
+	Region    models.Region
+	From      Status
+	To        Status
+	Node      string
+	Reason    string
+	ChangedAt time.Time
+}
+
+// TransitionStatus moves the match from one status to another and records the change in history.
+// It is a compare-and-set: when the row is no longer in the from status nothing is written and
+// false is returned, so two reconcilers racing over the same match record the change once.
+func TransitionStatus(matchId int64, from, to Status, node, reason string) (bool, error) {
+	db := ConnectAndMigrate()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var region models.Region
+	err = tx.QueryRow(`UPDATE match_resources SET status = $1 WHERE match_id = $2 AND status = $3 RETURNING region`, to, matchId, from).Scan(&region)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("Failed to update status: %v", err)
+		return false, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO match_status_history (match_id, region, from_status, to_status, node, reason) VALUES ($1, $2, $3, $4, $5, $6)`,
+		matchId, region, from, to, node, reason)
+	if err != nil {
+		log.Printf("Failed to record status history: %v", err)
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// FindMatchTimeline returns every recorded transition of the match, oldest first
+func FindMatchTimeline(matchId int64) ([]StatusTransition, error) {
+	return queryTransitions(`
+		SELECT match_id, region, from_status, to_status, node, reason, changed_at
+		FROM match_status_history
+		WHERE match_id = $1
+		ORDER BY changed_at, id
+	`, matchId)
+}
+
+// FindFailedMatches returns the transition into failed of every match in the region that failed after since, newest first
+func FindFailedMatches(region models.Region, since time.Time) ([]StatusTransition, error) {
+	return queryTransitions(`
+		SELECT match_id, region, from_status, to_status, node, reason, changed_at
+		FROM match_status_history
+		WHERE to_status = 'failed' AND region = $1 AND changed_at >= $2
+		ORDER BY changed_at DESC, id DESC
+	`, region, since)
+}
+
+func queryTransitions(query string, args ...interface{}) ([]StatusTransition, error) {
+	db := ConnectAndMigrate()
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []StatusTransition
+	for rows.Next() {
+		var t StatusTransition
+		if err := rows.Scan(&t.MatchId, &t.Region, &t.From, &t.To, &t.Node, &t.Reason, &t.ChangedAt); err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, t)
+	}
+	return transitions, rows.Err()
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+// QueuedLaunch is a launch command held back until its region has room
+type QueuedLaunch struct {
+	MatchId    int64
+	Region     models.Region
+	Priority   int
+	Command    models.LaunchGameServerCommand
+	EnqueuedAt time.Time
+	ReleasedAt *time.Time // set once the command was put back on the exchange
+}
+
+// EnqueueLaunch holds the command back. Queueing the same match twice keeps the first entry.
+func EnqueueLaunch(cmd *models.LaunchGameServerCommand, priority int) error {
+	command, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+
+	db := ConnectAndMigrate()
+	_, err = db.Exec(`
+		INSERT INTO launch_queue (match_id, region, priority, command)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (match_id) DO NOTHING
+	`, cmd.MatchID, cmd.Region, priority, command)
+	return err
+}
+
+// FindQueuedLaunch returns sql.ErrNoRows when the match isn't queued
+func FindQueuedLaunch(matchId int64) (*QueuedLaunch, error) {
+	db := ConnectAndMigrate()
+	return scanQueuedLaunch(db.QueryRow(`
+		SELECT match_id, region, priority, command, enqueued_at, released_at
+		FROM launch_queue WHERE match_id = $1
+	`, matchId))
+}
+
+// FindQueuedLaunches returns every queued launch, per region in admission order
+func FindQueuedLaunches() ([]QueuedLaunch, error) {
+	db := ConnectAndMigrate()
+	rows, err := db.Query(`
+		SELECT match_id, region, priority, command, enqueued_at, released_at
+		FROM launch_queue
+		ORDER BY region, priority DESC, enqueued_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var launches []QueuedLaunch
+	for rows.Next() {
+		l, err := scanQueuedLaunch(rows)
+		if err != nil {
+			return nil, err
+		}
+		launches = append(launches, *l)
+	}
+	return launches, rows.Err()
+}
+
+// CountWaitingLaunches counts the launches of the region that were not released yet
+func CountWaitingLaunches(region models.Region) (int, error) {
+	db := ConnectAndMigrate()
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM launch_queue WHERE region = $1 AND released_at IS NULL`, region).Scan(&count)
+	return count, err
+}
+
+// SetQueuedLaunchReleased marks the launch as put back on the exchange, or takes that back
+// when publishing failed
+func SetQueuedLaunchReleased(matchId int64, released bool) error {
+	db := ConnectAndMigrate()
+	var err error
+	if released {
+		_, err = db.Exec(`UPDATE launch_queue SET released_at = NOW() WHERE match_id = $1`, matchId)
+	} else {
+		_, err = db.Exec(`UPDATE launch_queue SET released_at = NULL WHERE match_id = $1`, matchId)
+	}
+	return err
+}
+
+func DeleteQueuedLaunch(matchId int64) error {
+	db := ConnectAndMigrate()
+	_, err := db.Exec(`DELETE FROM launch_queue WHERE match_id = $1`, matchId)
+	return err
+}
+
+func scanQueuedLaunch(row rowScanner) (*QueuedLaunch, error) {
+	var l QueuedLaunch
+	var command []byte
+	var releasedAt sql.NullTime
+	if err := row.Scan(&l.MatchId, &l.Region, &l.Priority, &command, &l.EnqueuedAt, &releasedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(command, &l.Command); err != nil {
+		return nil, err
+	}
+	if releasedAt.Valid {
+		l.ReleasedAt = &releasedAt.Time
+	}
+	return &l, nil
+}
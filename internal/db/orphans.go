@@ -0,0 +1,31 @@
+package db
+
+// OrphanAction is what the sweeper did with an object that had no match_resources row
+type OrphanAction string
+
+const (
+	OrphanDeleted OrphanAction = "deleted"
+	OrphanAdopted OrphanAction = "adopted"
+)
+
+type OrphanSweep struct {
+	MatchId int64 // 0 when the object name carried no match id
+	Kind    string
+	Name    string
+	Action  OrphanAction
+	Reason  string
+}
+
+// RecordOrphanSweep appends to the sweeper audit log
+func RecordOrphanSweep(sweep OrphanSweep) error {
+	db := ConnectAndMigrate()
+
+	var matchId interface{}
+	if sweep.MatchId != 0 {
+		matchId = sweep.MatchId
+	}
+
+	_, err := db.Exec(`INSERT INTO orphan_sweeps (match_id, kind, name, action, reason) VALUES ($1, $2, $3, $4, $5)`,
+		matchId, sweep.Kind, sweep.Name, sweep.Action, sweep.Reason)
+	return err
+}
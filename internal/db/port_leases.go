@@ -0,0 +1,187 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+const (
+	BasePort = 30100
+	MaxPort  = 36000
+
+	leaseAttempts = 5
+)
+
+var ErrNoFreePorts = errors.New("no free host ports left in region")
+
+// LeasePorts reserves a game/SourceTV host port pair for the match in the given region.
+// Pairs are aligned on even offsets from BasePort, so the tv port of one pair never
+// collides with the game port of another. Calling it again for the same match returns
+// the existing lease.
+func LeasePorts(matchId int64, region models.Region) (int, int, error) {
+	db := ConnectAndMigrate()
+
+	for attempt := 1; attempt <= leaseAttempts; attempt++ {
+		gsPort, tvPort, err := findLease(db, matchId)
+		if err == nil {
+			return gsPort, tvPort, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, 0, err
+		}
+
+		// Pick the lowest free pair. Two controllers racing for the same pair hit the
+		// unique constraint, insert nothing and try again.
+		row := db.QueryRow(`
+			INSERT INTO port_leases (match_id, region, game_port, tv_port)
+			SELECT $1, $2, p, p + 1
+			FROM generate_series($3::int, $4::int - 2, 2) AS p
+			WHERE NOT EXISTS (SELECT 1 FROM port_leases l WHERE l.region = $2 AND l.game_port = p)
+			ORDER BY p
+			LIMIT 1
+			ON CONFLICT DO NOTHING
+			RETURNING game_port, tv_port
+		`, matchId, region, BasePort, MaxPort)
+
+		err = row.Scan(&gsPort, &tvPort)
+		if err == nil {
+			return gsPort, tvPort, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, 0, err
+		}
+
+		if full, err := isRegionFull(db, region); err != nil {
+			return 0, 0, err
+		} else if full {
+			return 0, 0, ErrNoFreePorts
+		}
+
+		log.Printf("Port lease conflict for match %d (attempt %d/%d), retrying", matchId, attempt, leaseAttempts)
+	}
+
+	return 0, 0, ErrNoFreePorts
+}
+
+type PortLease struct {
+	MatchId  int64
+	Region   models.Region
+	GamePort int
+	TVPort   int
+}
+
+// FindAllPortLeases returns every lease keyed by match id
+func FindAllPortLeases() (map[int64]PortLease, error) {
+	db := ConnectAndMigrate()
+	rows, err := db.Query(`SELECT match_id, region, game_port, tv_port FROM port_leases`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leases := map[int64]PortLease{}
+	for rows.Next() {
+		var lease PortLease
+		if err := rows.Scan(&lease.MatchId, &lease.Region, &lease.GamePort, &lease.TVPort); err != nil {
+			return nil, err
+		}
+		leases[lease.MatchId] = lease
+	}
+	return leases, rows.Err()
+}
+
+// FindPortLease returns the game and SourceTV host ports leased by the match
+func FindPortLease(matchId int64) (int, int, error) {
+	return findLease(ConnectAndMigrate(), matchId)
+}
+
+// ClaimPortLease records ports a match already holds, e.g. a job adopted by the orphan sweeper.
+// It returns false if the match has a different lease or another match holds the ports.
+func ClaimPortLease(matchId int64, region models.Region, gsPort, tvPort int) (bool, error) {
+	db := ConnectAndMigrate()
+	_, err := db.Exec(`
+		INSERT INTO port_leases (match_id, region, game_port, tv_port)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING
+	`, matchId, region, gsPort, tvPort)
+	if err != nil {
+		return false, err
+	}
+
+	leasedGs, leasedTv, err := findLease(db, matchId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return leasedGs == gsPort && leasedTv == tvPort, nil
+}
+
+func findLease(db *sql.DB, matchId int64) (int, int, error) {
+	var gsPort, tvPort int
+	err := db.QueryRow(`SELECT game_port, tv_port FROM port_leases WHERE match_id=$1`, matchId).Scan(&gsPort, &tvPort)
+	return gsPort, tvPort, err
+}
+
+func isRegionFull(db *sql.DB, region models.Region) (bool, error) {
+	var leased int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM port_leases WHERE region=$1`, region).Scan(&leased); err != nil {
+		return false, err
+	}
+	return leased >= PortPairCapacity(), nil
+}
+
+// PortPairCapacity is the number of port pairs a single region can lease.
+func PortPairCapacity() int {
+	return (MaxPort - BasePort) / 2
+}
+
+func ReleasePorts(matchId int64) {
+	db := ConnectAndMigrate()
+	_, err := db.Exec(`DELETE FROM port_leases WHERE match_id=$1`, matchId)
+	if err != nil {
+		log.Printf("Failed to release ports for match %d: %v", matchId, err)
+	}
+}
+
+// ReclaimStalePortLeases drops leases whose match has no match_resources row and that don't belong to a warm server.
+// Leases are taken before the row is inserted, so only leases older than grace are touched.
+func ReclaimStalePortLeases(grace time.Duration) (int64, error) {
+	db := ConnectAndMigrate()
+	res, err := db.Exec(`
+		DELETE FROM port_leases l
+		WHERE l.leased_at < NOW() - make_interval(secs => $1)
+		  AND NOT EXISTS (SELECT 1 FROM match_resources mr WHERE mr.match_id = l.match_id)
+		  AND NOT EXISTS (SELECT 1 FROM warm_pool_members w WHERE -w.id = l.match_id)
+	`, grace.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// CountPortLeases returns the number of leased port pairs per region
+func CountPortLeases() (map[models.Region]int, error) {
+	db := ConnectAndMigrate()
+	rows, err := db.Query(`SELECT region, COUNT(*) FROM port_leases GROUP BY region`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[models.Region]int{}
+	for rows.Next() {
+		var region models.Region
+		var count int
+		if err := rows.Scan(&region, &count); err != nil {
+			return nil, err
+		}
+		counts[region] = count
+	}
+	return counts, rows.Err()
+}
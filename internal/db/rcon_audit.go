@@ -0,0 +1,18 @@
+package db
+
+type RconAudit struct {
+	MatchId  int64
+	Actor    string
+	Source   string // admin or redis
+	Command  string
+	Allowed  bool
+	Response string
+	Error    string
+}
+
+func RecordRconCommand(a RconAudit) error {
+	db := ConnectAndMigrate()
+	_, err := db.Exec(`INSERT INTO rcon_audit (match_id, actor, source, command, allowed, response, error) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		a.MatchId, a.Actor, a.Source, a.Command, a.Allowed, a.Response, a.Error)
+	return err
+}
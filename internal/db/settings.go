@@ -0,0 +1,191 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+// DefaultSettingsMode is the gameserver_settings row every mode inherits from
+const DefaultSettingsMode = -1
+
+const (
+	SourceBuiltin = "builtin"
+	SourceDefault = "default"
+	SourceMode    = "mode"
+	SourceRegion  = "region"
+)
+
+// settingsLayer is one row of settings; NULL columns inherit from the layer below
+type settingsLayer struct {
+	Source      string
+	TickRate    sql.NullInt64
+	Image       sql.NullString
+	LoadTimeout sql.NullInt64
+	CpuAffinity sql.NullBool
+
+	Resources        resourcesLayer
+	SidecarResources resourcesLayer
+	QosClass         sql.NullString
+	LaunchPriority   sql.NullInt64
+}
+
+type resourcesLayer struct {
+	CpuRequest    sql.NullString
+	CpuLimit      sql.NullString
+	MemoryRequest sql.NullString
+	MemoryLimit   sql.NullString
+}
+
+// SettingsSources records which layer supplied each resolved field
+type SettingsSources struct {
+	TickRate    string
+	Image       string
+	LoadTimeout string
+	CpuAffinity string
+	Resources   string // last layer that set any request or limit
+	QosClass    string
+	Priority    string
+}
+
+func (s SettingsSources) String() string {
+	return fmt.Sprintf("tickrate=%s image=%s load_timeout=%s cpu_affinity=%s resources=%s qos=%s priority=%s",
+		s.TickRate, s.Image, s.LoadTimeout, s.CpuAffinity, s.Resources, s.QosClass, s.Priority)
+}
+
+func builtinSettings(mode models.MatchmakingMode) GameServerSettings {
+	return GameServerSettings{
+		MatchmakingMode: int64(mode),
+		TickRate:        30,
+		LoadTimeout:     90,
+		CpuAffinity:     false,
+	}
+}
+
+// ResolveSettings merges the global default row, the mode row and the region row field by field.
+// Missing rows are skipped, so a mode without settings still launches on the defaults.
+func ResolveSettings(mode models.MatchmakingMode, region models.Region) (*GameServerSettings, error) {
+	db := ConnectAndMigrate()
+	rows, err := db.Query(`
+		SELECT source, tickrate, image, load_timeout, cpu_affinity,
+		       cpu_request, cpu_limit, memory_request, memory_limit,
+		       sidecar_cpu_request, sidecar_cpu_limit, sidecar_memory_request, sidecar_memory_limit, qos_class, launch_priority
+		FROM (
+			SELECT 1 AS layer, $3 AS source, tickrate, image, load_timeout, cpu_affinity,
+			       cpu_request, cpu_limit, memory_request, memory_limit,
+			       sidecar_cpu_request, sidecar_cpu_limit, sidecar_memory_request, sidecar_memory_limit, qos_class, launch_priority
+			FROM gameserver_settings WHERE matchmaking_mode = $6
+			UNION ALL
+			SELECT 2, $4, tickrate, image, load_timeout, cpu_affinity,
+			       cpu_request, cpu_limit, memory_request, memory_limit,
+			       sidecar_cpu_request, sidecar_cpu_limit, sidecar_memory_request, sidecar_memory_limit, qos_class, launch_priority
+			FROM gameserver_settings WHERE matchmaking_mode = $1
+			UNION ALL
+			-- resources and priority are per mode only
+			SELECT 3, $5, tickrate, image, load_timeout, cpu_affinity,
+			       NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL
+			FROM gameserver_region_settings WHERE region = $2
+		) layers
+		ORDER BY layer
+	`, mode, region, SourceDefault, SourceMode, SourceRegion, DefaultSettingsMode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var layers []settingsLayer
+	for rows.Next() {
+		var l settingsLayer
+		err := rows.Scan(&l.Source, &l.TickRate, &l.Image, &l.LoadTimeout, &l.CpuAffinity,
+			&l.Resources.CpuRequest, &l.Resources.CpuLimit, &l.Resources.MemoryRequest, &l.Resources.MemoryLimit,
+			&l.SidecarResources.CpuRequest, &l.SidecarResources.CpuLimit, &l.SidecarResources.MemoryRequest, &l.SidecarResources.MemoryLimit,
+			&l.QosClass, &l.LaunchPriority)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	settings, sources := mergeSettings(builtinSettings(mode), layers)
+
+	var found []string
+	for _, l := range layers {
+		found = append(found, l.Source)
+	}
+	log.Printf("Settings for mode %d in region %s (layers: %s): %s", mode, region, strings.Join(found, ","), sources)
+
+	return &settings, nil
+}
+
+func mergeSettings(base GameServerSettings, layers []settingsLayer) (GameServerSettings, SettingsSources) {
+	settings := base
+	sources := SettingsSources{
+		TickRate:    SourceBuiltin,
+		Image:       SourceBuiltin,
+		LoadTimeout: SourceBuiltin,
+		CpuAffinity: SourceBuiltin,
+		Resources:   SourceBuiltin,
+		QosClass:    SourceBuiltin,
+		Priority:    SourceBuiltin,
+	}
+
+	for _, l := range layers {
+		if l.TickRate.Valid {
+			settings.TickRate = int(l.TickRate.Int64)
+			sources.TickRate = l.Source
+		}
+		if l.Image.Valid && l.Image.String != "" {
+			settings.Image = l.Image.String
+			sources.Image = l.Source
+		}
+		if l.LoadTimeout.Valid {
+			settings.LoadTimeout = int(l.LoadTimeout.Int64)
+			sources.LoadTimeout = l.Source
+		}
+		if l.CpuAffinity.Valid {
+			settings.CpuAffinity = l.CpuAffinity.Bool
+			sources.CpuAffinity = l.Source
+		}
+		gsSet := mergeResources(&settings.Resources, l.Resources)
+		sidecarSet := mergeResources(&settings.SidecarResources, l.SidecarResources)
+		if gsSet || sidecarSet {
+			sources.Resources = l.Source
+		}
+		if l.QosClass.Valid && l.QosClass.String != "" {
+			settings.QosClass = l.QosClass.String
+			sources.QosClass = l.Source
+		}
+		if l.LaunchPriority.Valid {
+			settings.LaunchPriority = int(l.LaunchPriority.Int64)
+			sources.Priority = l.Source
+		}
+	}
+
+	return settings, sources
+}
+
+// mergeResources overrides field by field and reports whether the layer set anything
+func mergeResources(dst *ContainerResources, l resourcesLayer) bool {
+	set := false
+	for _, f := range []struct {
+		dst *string
+		src sql.NullString
+	}{
+		{&dst.CpuRequest, l.CpuRequest},
+		{&dst.CpuLimit, l.CpuLimit},
+		{&dst.MemoryRequest, l.MemoryRequest},
+		{&dst.MemoryLimit, l.MemoryLimit},
+	} {
+		if f.src.Valid && f.src.String != "" {
+			*f.dst = f.src.String
+			set = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestMergeSettingsFieldByField(t *testing.T) {
+	base := builtinSettings(7)
+	layers := []settingsLayer{
+		{Source: SourceDefault, TickRate: sql.NullInt64{Int64: 30, Valid: true}, LoadTimeout: sql.NullInt64{Int64: 90, Valid: true}},
+		{Source: SourceMode, TickRate: sql.NullInt64{Int64: 40, Valid: true}, CpuAffinity: sql.NullBool{Bool: true, Valid: true}},
+		{Source: SourceRegion, Image: sql.NullString{String: "srcds:region", Valid: true}, LoadTimeout: sql.NullInt64{Int64: 120, Valid: true}},
+	}
+
+	settings, sources := mergeSettings(base, layers)
+
+	if settings.TickRate != 40 || sources.TickRate != SourceMode {
+		t.Errorf("tickrate: got %d from %s, want 40 from mode", settings.TickRate, sources.TickRate)
+	}
+	if settings.LoadTimeout != 120 || sources.LoadTimeout != SourceRegion {
+		t.Errorf("load timeout: got %d from %s, want 120 from region", settings.LoadTimeout, sources.LoadTimeout)
+	}
+	if !settings.CpuAffinity || sources.CpuAffinity != SourceMode {
+		t.Errorf("cpu affinity: got %v from %s, want true from mode", settings.CpuAffinity, sources.CpuAffinity)
+	}
+	if settings.Image != "srcds:region" || sources.Image != SourceRegion {
+		t.Errorf("image: got %s from %s, want srcds:region from region", settings.Image, sources.Image)
+	}
+}
+
+func TestMergeSettingsWithoutRows(t *testing.T) {
+	settings, sources := mergeSettings(builtinSettings(3), nil)
+
+	if settings.TickRate != 30 || settings.LoadTimeout != 90 || settings.CpuAffinity {
+		t.Errorf("expected builtin defaults, got %+v", settings)
+	}
+	if sources.TickRate != SourceBuiltin || sources.Image != SourceBuiltin {
+		t.Errorf("expected builtin sources, got %s", sources)
+	}
+}
+
+func TestMergeSettingsResources(t *testing.T) {
+	layers := []settingsLayer{
+		{Source: SourceDefault, Resources: resourcesLayer{CpuRequest: sql.NullString{String: "300m", Valid: true}, MemoryRequest: sql.NullString{String: "350Mi", Valid: true}}},
+		{Source: SourceMode, Resources: resourcesLayer{CpuRequest: sql.NullString{String: "100m", Valid: true}}, QosClass: sql.NullString{String: "Burstable", Valid: true}},
+	}
+
+	settings, sources := mergeSettings(builtinSettings(9), layers)
+
+	if settings.Resources.CpuRequest != "100m" || settings.Resources.MemoryRequest != "350Mi" {
+		t.Errorf("resources: got %+v, want cpu from mode and memory from default", settings.Resources)
+	}
+	if settings.SidecarResources != (ContainerResources{}) {
+		t.Errorf("sidecar resources: got %+v, want none", settings.SidecarResources)
+	}
+	if sources.Resources != SourceMode || settings.QosClass != "Burstable" || sources.QosClass != SourceMode {
+		t.Errorf("sources: got %s, want resources and qos from mode", sources)
+	}
+}
+
+func TestMergeSettingsLaunchPriority(t *testing.T) {
+	layers := []settingsLayer{
+		{Source: SourceDefault, LaunchPriority: sql.NullInt64{Int64: 10, Valid: true}},
+		{Source: SourceMode},
+	}
+
+	settings, sources := mergeSettings(builtinSettings(7), layers)
+
+	if settings.LaunchPriority != 10 || sources.Priority != SourceDefault {
+		t.Errorf("priority: got %d from %s, want 10 from default", settings.LaunchPriority, sources.Priority)
+	}
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+type WarmPoolSetting struct {
+	Region models.Region
+	Patch  models.DotaPatch
+	Size   int
+}
+
+// WarmServer is an idle gameserver job waiting for a match
+type WarmServer struct {
+	Id            int64
+	Region        models.Region
+	Patch         models.DotaPatch
+	Image         string
+	JobName       string
+	ConfigMapName string
+	SecretName    string
+	Ready         bool
+	CreatedAt     time.Time
+}
+
+// LeaseId is the port_leases key of an unclaimed warm server. Match ids are positive, so they never collide.
+func (w *WarmServer) LeaseId() int64 {
+	return -w.Id
+}
+
+// FindWarmPoolSettings returns the pool sizes of enabled regions
+func FindWarmPoolSettings() ([]WarmPoolSetting, error) {
+	db := ConnectAndMigrate()
+	rows, err := db.Query(`
+		SELECT s.region, s.patch, s.size
+		FROM warm_pool_settings s
+		JOIN gameserver_regions r ON r.region = s.region
+		WHERE r.enabled
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var settings []WarmPoolSetting
+	for rows.Next() {
+		var s WarmPoolSetting
+		if err := rows.Scan(&s.Region, &s.Patch, &s.Size); err != nil {
+			return nil, err
+		}
+		settings = append(settings, s)
+	}
+	return settings, rows.Err()
+}
+
+// InsertWarmServer registers a new warm server; object names are derived from its id
+func InsertWarmServer(region models.Region, patch models.DotaPatch, image string) (*WarmServer, error) {
+	db := ConnectAndMigrate()
+	row := db.QueryRow(`
+		INSERT INTO warm_pool_members (id, region, patch, image, job_name, config_map_name, secret_name)
+		SELECT n, $1, $2, $3, 'gameserver-warm-job-' || n, 'gameserver-warm-config-' || n, 'gameserver-warm-secrets-' || n
+		FROM nextval('warm_pool_members_id_seq') AS n
+		RETURNING id, region, patch, image, job_name, config_map_name, secret_name, ready, created_at
+	`, region, patch, image)
+	return scanWarmServer(row)
+}
+
+func FindWarmServers() ([]WarmServer, error) {
+	db := ConnectAndMigrate()
+	rows, err := db.Query(`SELECT id, region, patch, image, job_name, config_map_name, secret_name, ready, created_at FROM warm_pool_members ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var servers []WarmServer
+	for rows.Next() {
+		ws, err := scanWarmServer(rows)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, *ws)
+	}
+	return servers, rows.Err()
+}
+
+func MarkWarmServerReady(id int64) error {
+	db := ConnectAndMigrate()
+	_, err := db.Exec(`UPDATE warm_pool_members SET ready = true WHERE id = $1`, id)
+	return err
+}
+
+// DeleteWarmServer forgets the warm server and gives its ports back. It returns false when there was no
+// row left to delete, i.e. a match claimed the server in the meantime and now owns its objects and ports.
+func DeleteWarmServer(id int64) (bool, error) {
+	db := ConnectAndMigrate()
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var deleted int64
+	err = tx.QueryRow(`DELETE FROM warm_pool_members WHERE id = $1 RETURNING id`, id).Scan(&deleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM port_leases WHERE match_id = $1`, -id); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// ClaimWarmServer hands the oldest ready warm server of the region and image to the match.
+// In one transaction it removes the pool entry, moves the port lease to the match id and inserts the
+// provisioning match_resources row, so a crash leaves either a warm server or a match, never both.
+// It returns sql.ErrNoRows when the pool is empty.
+func ClaimWarmServer(region models.Region, image string, matchId int64) (*WarmServer, error) {
+	db := ConnectAndMigrate()
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ws, err := scanWarmServer(tx.QueryRow(`
+		DELETE FROM warm_pool_members
+		WHERE id = (
+			SELECT id FROM warm_pool_members
+			WHERE region = $1 AND image = $2 AND ready
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, region, patch, image, job_name, config_map_name, secret_name, ready, created_at
+	`, region, image))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`UPDATE port_leases SET match_id = $1 WHERE match_id = $2`, matchId, ws.LeaseId()); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO match_resources (match_id, job_name, secret_name, config_map_name, region, status) VALUES ($1, $2, $3, $4, $5, $6)`,
+		matchId, ws.JobName, ws.SecretName, ws.ConfigMapName, region, StatusProvisioning)
+	if err != nil {
+		return nil, err
+	}
+
+	return ws, tx.Commit()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWarmServer(row rowScanner) (*WarmServer, error) {
+	var ws WarmServer
+	err := row.Scan(&ws.Id, &ws.Region, &ws.Patch, &ws.Image, &ws.JobName, &ws.ConfigMapName, &ws.SecretName, &ws.Ready, &ws.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &ws, nil
+}
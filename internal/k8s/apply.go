@@ -3,9 +3,13 @@ package k8s
 import (
 	"context"
 	"d2c-gs-controller/internal/db"
-	"d2c-gs-controller/internal/redis"
+	"d2c-gs-controller/internal/metrics"
 	"d2c-gs-controller/internal/util"
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"time"
 
 	"log"
 
@@ -14,6 +18,7 @@ import (
 	"github.com/dota2classic/d2c-go-models/models"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
@@ -25,97 +30,167 @@ type DeployedMatch struct {
 	JobName       string
 }
 
-var ErrJobAlreadyExists = errors.New("gameserver already running")
+// MatchPlan holds the rendered Kubernetes objects of a match, ready to be applied
+type MatchPlan struct {
+	MatchId   int64
+	Region    models.Region
+	ConfigMap *corev1.ConfigMap
+	Secret    *corev1.Secret
+	Job       *batchv1.Job
+}
 
-func DeployMatchResources(ctx context.Context, clientset *kubernetes.Clientset, evt *models.LaunchGameServerCommand) (*DeployedMatch, error) {
+func (p *MatchPlan) Names() *DeployedMatch {
+	return &DeployedMatch{
+		ConfigMapName: p.ConfigMap.Name,
+		SecretName:    p.Secret.Name,
+		JobName:       p.Job.Name,
+	}
+}
 
-	password, err := util.GenerateSecureRandomString(12)
+// PlanMatchResources resolves settings, image and host ports and renders the match objects.
+// It leases the ports but doesn't touch the cluster.
+func PlanMatchResources(evt *models.LaunchGameServerCommand) (*MatchPlan, error) {
+	password := generateRconPassword()
+	log.Printf("RCON password length for match %d: %d", evt.MatchID, len(password))
+
+	gsPort, tvPort, err := db.LeasePorts(evt.MatchID, evt.Region)
 
 	if err != nil {
-		log.Printf("Error generating RCON password: %v, using fallback", err)
-		password = "rconpassword"
+		log.Printf("Error allocating game server ports: %v", err)
+		return nil, err
 	}
 
-	if password == "" {
-		log.Printf("WARNING: Generated RCON password is empty, using fallback")
-		password = "rconpassword"
+	// Give the ports back if planning fails
+	releasePorts := true
+	defer func() {
+		if releasePorts {
+			db.ReleasePorts(evt.MatchID)
+		}
+	}()
+
+	gameServerSettings, err := db.ResolveSettings(evt.LobbyType, evt.Region)
+	if err != nil {
+		log.Printf("Error resolving gameserver settings for mode %d: %v", evt.LobbyType, err)
+		return nil, err
 	}
 
-	log.Printf("RCON password length for match %d: %d", evt.MatchID, len(password))
+	jobTemplate := CpuAffinityJobTemplate
 
-	gsPort, tvPort, err := redis.AllocateGameServerPorts()
+	if gameServerSettings.CpuAffinity {
+		jobTemplate = CpuAffinityJobTemplate
+	} else {
+		jobTemplate = RegularJobTemplate
+	}
 
+	image, err := ResolveGameServerImage(evt, gameServerSettings)
 	if err != nil {
-		log.Printf("Error allocating game server ports: %v", err)
+		log.Printf("Error resolving gameserver image: %v", err)
 		return nil, err
 	}
 
-	runSchema, err := constructMatchInfoJson(evt)
+	data, err := newMatchTemplateData(evt, gameServerSettings)
 	if err != nil {
-		log.Printf("Error constructing MatchInfoJson: %v", err)
 		return nil, err
 	}
 
-	//priorityLobby := evt.LobbyType == models.MATCHMAKING_MODE_LOBBY || evt.LobbyType == models.MATCHMAKING_MODE_UNRANKED
-	cfgName := "server.cfg"
+	data.GameServerResources, data.SidecarResources, err = buildResources(gameServerSettings)
+	if err != nil {
+		log.Printf("Error building resources for mode %d: %v", evt.LobbyType, err)
+		return nil, err
+	}
+	data.ArtifactsResources, err = artifactsResources(gameServerSettings.QosClass)
+	if err != nil {
+		log.Printf("Error building artifact gate resources for mode %d: %v", evt.LobbyType, err)
+		return nil, err
+	}
 
-	tickrate := 30
-	// FIXME: this fails really bad if there is no settings for this mode. we should just fail with warning, not crash completely
-	gameServerSettings, err := db.GetSettingsForMode(evt.LobbyType)
+	data.RconPassword = password
+	data.GameServerImage = image
+	data.HostGamePort = gsPort
+	data.HostSourceTVPort = tvPort
+	data.ArtifactsHoldSeconds = artifactsHoldSeconds()
 
+	configMap, err := createConfiguration[corev1.ConfigMap](ConfigmapTemplate, data)
 	if err != nil {
-		log.Printf("Error getting gameserver settings for mode %d: %v", evt.LobbyType, err)
-	} else {
-		tickrate = gameServerSettings.TickRate
+		log.Printf("Error rendering ConfigMap: %v", err)
+		return nil, err
 	}
 
-	jobTemplate := CpuAffinityJobTemplate
+	secret, err := createConfiguration[corev1.Secret](SecretTemplate, data)
+	if err != nil {
+		log.Printf("Error rendering Secret: %v", err)
+		return nil, err
+	}
 
-	if gameServerSettings.CpuAffinity {
-		jobTemplate = CpuAffinityJobTemplate
-	} else {
-		jobTemplate = RegularJobTemplate
+	job, err := createConfiguration[batchv1.Job](jobTemplate, data)
+	if err != nil {
+		log.Printf("Error rendering Job: %v", err)
+		return nil, err
 	}
 
-	abandonHighQuality := 0
-	if evt.LobbyType == models.MATCHMAKING_MODE_HIGHROOM || evt.LobbyType == models.MATCHMAKING_MODE_UNRANKED {
-		abandonHighQuality = 1
+	releasePorts = false
+	return &MatchPlan{
+		MatchId:   evt.MatchID,
+		Region:    evt.Region,
+		ConfigMap: configMap,
+		Secret:    secret,
+		Job:       job,
+	}, nil
+}
+
+func generateRconPassword() string {
+	password, err := util.GenerateSecureRandomString(12)
+
+	if err != nil {
+		log.Printf("Error generating RCON password: %v, using fallback", err)
+		password = "rconpassword"
 	}
 
-	botDifficulty := 3
-	if evt.LobbyType == models.MATCHMAKING_MODE_BOTS {
-		botDifficulty = 2
+	if password == "" {
+		log.Printf("WARNING: Generated RCON password is empty, using fallback")
+		password = "rconpassword"
 	}
+	return password
+}
 
-	image := "dota2classic/srcds:d684-latest"
+// newMatchTemplateData fills in everything a gameserver needs to know about the match itself.
+// Password, image and ports belong to the pod and are set by the caller.
+func newMatchTemplateData(evt *models.LaunchGameServerCommand, settings *db.GameServerSettings) (*templateData, error) {
+	failover, err := db.FindLastFailover(evt.MatchID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error looking up failover of match %d: %v", evt.MatchID, err)
+		return nil, err
+	}
 
-	switch evt.Patch {
-	case models.PATCH_DOTA_684_TURBO:
-		image = "dota2classic/srcds:d684-turbo-latest"
-	case models.PATCH_DOTA_684:
-		image = "dota2classic/srcds:d684-latest"
-	case models.PATCH_DOTA_688:
-		image = "dota2classic/srcds:d684-crash-fix-latest"
+	runSchema, err := constructMatchInfoJson(evt, failover)
+	if err != nil {
+		log.Printf("Error constructing MatchInfoJson: %v", err)
+		return nil, err
 	}
 
-	log.Printf("Launching on image %s because received patch was %s", image, evt.Patch)
+	//priorityLobby := evt.LobbyType == models.MATCHMAKING_MODE_LOBBY || evt.LobbyType == models.MATCHMAKING_MODE_UNRANKED
+	cfgName := "server.cfg"
 
-	data := templateData{
-		MatchId:      evt.MatchID,
-		GameMode:     evt.GameMode,
-		LobbyType:    evt.LobbyType,
-		Map:          evt.Map,
-		Region:       evt.Region,
-		RconPassword: password,
-		MatchJson:    runSchema,
-		TickRate:     tickrate,
-		ConfigName:   cfgName,
-		LoadTimeout:  gameServerSettings.LoadTimeout,
+	abandonHighQuality := 0
+	if evt.LobbyType == models.MATCHMAKING_MODE_HIGHROOM || evt.LobbyType == models.MATCHMAKING_MODE_UNRANKED {
+		abandonHighQuality = 1
+	}
 
-		GameServerImage: image, // Infer image from "Patch"
+	botDifficulty := 3
+	if evt.LobbyType == models.MATCHMAKING_MODE_BOTS {
+		botDifficulty = 2
+	}
 
-		HostGamePort:     gsPort,
-		HostSourceTVPort: tvPort,
+	return &templateData{
+		MatchId:     evt.MatchID,
+		GameMode:    evt.GameMode,
+		LobbyType:   evt.LobbyType,
+		Map:         evt.Map,
+		Region:      evt.Region,
+		MatchJson:   runSchema,
+		TickRate:    settings.TickRate,
+		ConfigName:  cfgName,
+		LoadTimeout: settings.LoadTimeout,
 
 		// Plugins
 		DisableRunes:       util.BoolToInt(evt.Params.NoRunes),
@@ -124,86 +199,120 @@ func DeployMatchResources(ctx context.Context, clientset *kubernetes.Clientset,
 		EnableBans:         util.BoolToInt(evt.Params.EnableBanStage),
 		AbandonHighQuality: abandonHighQuality,
 		BotDifficulty:      botDifficulty,
-	}
+	}, nil
+}
+
+// ApplyMatchPlan creates the match objects. Every step accepts objects left behind by an earlier
+// attempt, so a redelivered command can resume a half-finished launch. On error the caller rolls back.
+func ApplyMatchPlan(ctx context.Context, clientset *kubernetes.Clientset, plan *MatchPlan) error {
+	started := time.Now()
+	defer func() {
+		metrics.DeployDuration.WithLabelValues(string(plan.Region)).Observe(time.Since(started).Seconds())
+	}()
 
 	// --- 1. CONFIGMAP ---
-	configMap, err := ensureConfigMap(ctx, clientset, Namespace, &data)
-	if err != nil {
-		return nil, err
+	if err := ensureConfigMap(ctx, clientset, Namespace, plan.ConfigMap); err != nil {
+		return err
 	}
 
 	// --- 2. SECRET ---
-	secret, err := ensureSecret(ctx, clientset, Namespace, &data)
-	if err != nil {
-		return nil, err
+	if err := ensureSecret(ctx, clientset, Namespace, plan.Secret); err != nil {
+		return err
 	}
 
 	// --- 3. JOB ---
-	job, err := createJob(ctx, clientset, Namespace, jobTemplate, &data)
+	job, err := ensureJob(ctx, clientset, Namespace, plan.Job)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return &DeployedMatch{
-		ConfigMapName: configMap.Name,
-		SecretName:    secret.Name,
-		JobName:       job.Name,
-	}, nil
+	// --- 4. OWNERSHIP ---
+	// ConfigMap and Secret go away with the Job, even if we never get to delete them ourselves
+	return setJobOwner(ctx, clientset, Namespace, job, plan)
 }
 
-func ensureConfigMap(ctx context.Context, clientset *kubernetes.Clientset, namespace string, data *templateData) (*corev1.ConfigMap, error) {
-	configMap, err := createConfiguration[corev1.ConfigMap](ConfigmapTemplate, data)
-	_, err = clientset.CoreV1().ConfigMaps(namespace).Create(ctx, configMap, metav1.CreateOptions{})
+func ensureConfigMap(ctx context.Context, clientset *kubernetes.Clientset, namespace string, configMap *corev1.ConfigMap) error {
+	_, err := clientset.CoreV1().ConfigMaps(namespace).Create(ctx, configMap, metav1.CreateOptions{})
 	if err != nil {
 		if k8serrors.IsAlreadyExists(err) {
 			log.Printf("ConfigMap already exists - updating")
-			// Optionally you can update it instead:
 			_, err = clientset.CoreV1().ConfigMaps(namespace).Update(ctx, configMap, metav1.UpdateOptions{})
-			return configMap, nil
+			return err
 		}
 		log.Printf("Error creating ConfigMap: %v", err)
-		return nil, err
+		return err
 	}
 	log.Println("Created ConfigMap")
-	return configMap, nil
+	return nil
 }
 
-func ensureSecret(ctx context.Context, clientset *kubernetes.Clientset, namespace string, data *templateData) (*corev1.Secret, error) {
-	secret, err := createConfiguration[corev1.Secret](SecretTemplate, data)
-	_, err = clientset.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{})
-
+func ensureSecret(ctx context.Context, clientset *kubernetes.Clientset, namespace string, secret *corev1.Secret) error {
+	_, err := clientset.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{})
 	if err != nil {
 		if k8serrors.IsAlreadyExists(err) {
-			log.Printf("Secret already exists - updating")
-			// Optionally you can update it instead:
-			_, err = clientset.CoreV1().Secrets(namespace).Update(ctx, secret, metav1.UpdateOptions{})
-			return secret, nil
+			// Keep it: a job from an earlier attempt may already run with this RCON password
+			log.Printf("Secret already exists - keeping it")
+			return nil
 		}
 		log.Printf("Error creating Secret: %v", err)
-		return nil, err
+		return err
 	}
 	log.Println("Created Secret")
-	return secret, nil
+	return nil
 }
 
-func createJob(
-	ctx context.Context,
-	clientset *kubernetes.Clientset,
-	namespace string,
-	template string,
-	data *templateData,
-) (*batchv1.Job, error) {
-	job, err := createConfiguration[batchv1.Job](template, data)
-	_, err = clientset.BatchV1().Jobs(namespace).Create(ctx, job, metav1.CreateOptions{})
-
+// ensureJob returns the job as stored by the API server, so its UID can be referenced
+func ensureJob(ctx context.Context, clientset *kubernetes.Clientset, namespace string, job *batchv1.Job) (*batchv1.Job, error) {
+	created, err := clientset.BatchV1().Jobs(namespace).Create(ctx, job, metav1.CreateOptions{})
 	if err != nil {
 		if k8serrors.IsAlreadyExists(err) {
-			log.Printf("Job already exists: we fail hard")
-			return nil, ErrJobAlreadyExists
+			existing, err := clientset.BatchV1().Jobs(namespace).Get(ctx, job.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			// After a failover the job of the same name may still be on its way out of the old region
+			if existing.DeletionTimestamp != nil || existing.Labels[RegionLabel] != job.Labels[RegionLabel] {
+				return nil, fmt.Errorf("job %s of an earlier attempt in region %s is still being deleted", job.Name, existing.Labels[RegionLabel])
+			}
+			log.Printf("Job already exists - launched by an earlier attempt")
+			return existing, nil
 		}
 		log.Printf("Error creating job: %v", err)
 		return nil, err
 	}
 	log.Println("Created Job!")
-	return job, nil
+	return created, nil
+}
+
+func setJobOwner(ctx context.Context, clientset *kubernetes.Clientset, namespace string, job *batchv1.Job, plan *MatchPlan) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"ownerReferences": []metav1.OwnerReference{jobOwnerReference(job)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = clientset.CoreV1().ConfigMaps(namespace).Patch(ctx, plan.ConfigMap.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		log.Printf("Error setting owner of ConfigMap: %v", err)
+		return err
+	}
+
+	_, err = clientset.CoreV1().Secrets(namespace).Patch(ctx, plan.Secret.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		log.Printf("Error setting owner of Secret: %v", err)
+		return err
+	}
+	return nil
+}
+
+func jobOwnerReference(job *batchv1.Job) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+		Name:       job.Name,
+		UID:        job.UID,
+	}
 }
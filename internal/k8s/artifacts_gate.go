@@ -0,0 +1,51 @@
+package k8s
+
+import (
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/util"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+/**
+Artifact gate: with artifact storage configured, every gameserver pod gets an "artifacts" container that
+mounts the replays, logs and dumps volumes under /artifacts and keeps running until ArtifactsGateFile exists.
+The pod can't complete before that, so the volumes stay around until the controller has copied them out.
+ARTIFACTS_HOLD_TIMEOUT (default 15m) lets the pod go anyway when the controller is gone for longer.
+Its resources follow the QoS class of the mode like the other containers, and count in MatchRequests.
+*/
+
+const (
+	ArtifactsContainer = "artifacts"
+	ArtifactsDir       = "/artifacts"
+	ArtifactsGateFile  = "/gate/collected"
+)
+
+// ArtifactsEnabled reports whether artifact storage is configured, see the artifacts package
+func ArtifactsEnabled() bool {
+	return os.Getenv("ARTIFACTS_ENDPOINT") != "" && os.Getenv("ARTIFACTS_BUCKET") != ""
+}
+
+// artifactsDefaults fit a sleep loop and the tar the controller execs. The memory limit equals its request,
+// so Guaranteed only has to fill in the cpu limit.
+var artifactsDefaults = db.ContainerResources{CpuRequest: "10m", MemoryRequest: "32Mi", MemoryLimit: "32Mi"}
+
+// artifactsResources applies the QoS class of the mode to the gate container
+func artifactsResources(qos string) (corev1.ResourceRequirements, error) {
+	return containerResources(ArtifactsContainer, artifactsDefaults, qos)
+}
+
+// artifactsHoldSeconds is 0 when there is no storage, which leaves the gate out of the templates
+func artifactsHoldSeconds() int {
+	if !ArtifactsEnabled() {
+		return 0
+	}
+	return int(ArtifactsHoldTimeout().Seconds())
+}
+
+// ArtifactsHoldTimeout is how long the gate keeps a pod after its start when the controller never opens it
+func ArtifactsHoldTimeout() time.Duration {
+	return util.GetEnvDuration("ARTIFACTS_HOLD_TIMEOUT", "15m")
+}
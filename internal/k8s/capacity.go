@@ -0,0 +1,301 @@
+package k8s
+
+import (
+	"context"
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/util"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+/**
+Capacity estimate of a region, taken before a launch:
+- schedulable gameserver nodes of the region offer their allocatable cpu, memory and pod count
+- every pod that runs on them takes its requests off
+- match pods of the region that still wait for a node take one slot each
+- the region needs a free host port pair as well
+The scheduler still has the last word; launches that never start are timed out by reconcile as before.
+The estimate reads from informers on nodes and on the non-terminal pods of every namespace. Each replica
+keeps a copy of all of those pods, trimmed to name, labels, node and requests, and its service account needs
+list and watch on nodes and on pods cluster-wide.
+*/
+
+const (
+	RegionLabel   = "ru.dotaclassic/region"
+	nodeTypeLabel = "ru.dotaclassic/nodeType"
+)
+
+type RegionCapacity struct {
+	Region      models.Region
+	Nodes       int // schedulable gameserver nodes
+	Slots       int // matches of the requested size that still fit on them
+	Unscheduled int // match pods of the region waiting for a node
+	FreePorts   int // host port pairs left
+}
+
+// Fits reports whether one more match can start
+func (c *RegionCapacity) Fits() bool {
+	return c.Slots > c.Unscheduled && c.FreePorts > 0
+}
+
+// Reserve accounts for a launch admitted on this estimate
+func (c *RegionCapacity) Reserve() {
+	c.Unscheduled++
+	c.FreePorts--
+}
+
+func (c *RegionCapacity) String() string {
+	return fmt.Sprintf("region %s has %d gameserver nodes with room for %d matches, %d waiting for a node, %d free port pairs",
+		c.Region, c.Nodes, c.Slots, c.Unscheduled, c.FreePorts)
+}
+
+// MatchRequests is what the pod of a match with these settings asks the scheduler for
+func MatchRequests(settings *db.GameServerSettings) (corev1.ResourceList, error) {
+	gameserver, sidecar, err := buildResources(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	total := corev1.ResourceList{}
+	addResources(total, gameserver.Requests)
+	addResources(total, sidecar.Requests)
+
+	if ArtifactsEnabled() {
+		gate, err := artifactsResources(settings.QosClass)
+		if err != nil {
+			return nil, err
+		}
+		addResources(total, gate.Requests)
+	}
+	return total, nil
+}
+
+var ErrCapacityUnavailable = errors.New("capacity informers not synced")
+
+var (
+	capacityMu      sync.Mutex
+	nodeLister      corelisters.NodeLister
+	podLister       corelisters.PodLister
+	capacityRetryAt time.Time
+)
+
+// capacityListers starts the node and pod informers the estimates read from on first use and waits for
+// their first sync. They live as long as the process, so a launch costs no LIST against the API server.
+// A sync that doesn't finish within CAPACITY_SYNC_TIMEOUT is given up, and tried again after CAPACITY_SYNC_RETRY;
+// until then estimates fail right away, which lets admission through.
+func capacityListers() (corelisters.NodeLister, corelisters.PodLister, error) {
+	capacityMu.Lock()
+	defer capacityMu.Unlock()
+
+	if nodeLister != nil {
+		return nodeLister, podLister, nil
+	}
+	if time.Now().Before(capacityRetryAt) {
+		return nil, nil, fmt.Errorf("%w, next try at %s", ErrCapacityUnavailable, capacityRetryAt.Format(time.TimeOnly))
+	}
+
+	client := GetClient()
+	resync := util.GetEnvDuration("INFORMER_RESYNC_INTERVAL", "30s")
+
+	nodeFactory := informers.NewSharedInformerFactoryWithOptions(client, resync,
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = fmt.Sprintf("%s=gameserver", nodeTypeLabel)
+		}),
+	)
+	// Other workloads on gameserver nodes take room too, so every namespace counts
+	podFactory := informers.NewSharedInformerFactoryWithOptions(client, resync,
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.FieldSelector = "status.phase!=Succeeded,status.phase!=Failed"
+		}),
+		informers.WithTransform(trimPod),
+	)
+
+	nodes := nodeFactory.Core().V1().Nodes()
+	pods := podFactory.Core().V1().Pods()
+	nodes.Informer()
+	pods.Informer()
+
+	stop := make(chan struct{})
+	nodeFactory.Start(stop)
+	podFactory.Start(stop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), util.GetEnvDuration("CAPACITY_SYNC_TIMEOUT", "10s"))
+	defer cancel()
+
+	for _, synced := range []map[reflect.Type]bool{nodeFactory.WaitForCacheSync(ctx.Done()), podFactory.WaitForCacheSync(ctx.Done())} {
+		for informerType, ok := range synced {
+			if !ok {
+				close(stop)
+				capacityRetryAt = time.Now().Add(util.GetEnvDuration("CAPACITY_SYNC_RETRY", "1m"))
+				return nil, nil, fmt.Errorf("%w: %s cache did not sync, check list and watch permissions", ErrCapacityUnavailable, informerType)
+			}
+		}
+	}
+
+	log.Printf("Capacity informers synced")
+	nodeLister = nodes.Lister()
+	podLister = pods.Lister()
+	return nodeLister, podLister, nil
+}
+
+// trimPod keeps only what the estimate reads, so the cache of every pod in the cluster stays small
+func trimPod(obj interface{}) (interface{}, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return obj, nil // tombstones of deleted pods
+	}
+
+	trimmed := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            pod.Name,
+			Namespace:       pod.Namespace,
+			UID:             pod.UID,
+			ResourceVersion: pod.ResourceVersion,
+			Labels:          pod.Labels,
+		},
+		Spec:   corev1.PodSpec{NodeName: pod.Spec.NodeName},
+		Status: corev1.PodStatus{Phase: pod.Status.Phase},
+	}
+	for _, c := range pod.Spec.Containers {
+		trimmed.Spec.Containers = append(trimmed.Spec.Containers, corev1.Container{Name: c.Name, Resources: c.Resources})
+	}
+	for _, c := range pod.Spec.InitContainers {
+		trimmed.Spec.InitContainers = append(trimmed.Spec.InitContainers, corev1.Container{Name: c.Name, Resources: c.Resources})
+	}
+	return trimmed, nil
+}
+
+// FindRegionCapacity estimates how many matches with the given requests the region can still take
+func FindRegionCapacity(region models.Region, request corev1.ResourceList) (*RegionCapacity, error) {
+	nodes, pods, err := capacityListers()
+	if err != nil {
+		return nil, err
+	}
+
+	regionNodes, err := nodes.List(labels.SelectorFromSet(labels.Set{RegionLabel: string(region)}))
+	if err != nil {
+		return nil, err
+	}
+	allPods, err := pods.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	leases, err := db.CountPortLeases()
+	if err != nil {
+		return nil, err
+	}
+
+	capacity := regionCapacity(region, regionNodes, allPods, request)
+	capacity.FreePorts = db.PortPairCapacity() - leases[region]
+	return capacity, nil
+}
+
+func regionCapacity(region models.Region, nodes []*corev1.Node, pods []*corev1.Pod, request corev1.ResourceList) *RegionCapacity {
+	capacity := &RegionCapacity{Region: region}
+
+	podsByNode := map[string][]*corev1.Pod{}
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" {
+			if pod.Namespace == Namespace && pod.Labels[RegionLabel] == string(region) {
+				capacity.Unscheduled++
+			}
+			continue
+		}
+		podsByNode[pod.Spec.NodeName] = append(podsByNode[pod.Spec.NodeName], pod)
+	}
+
+	for _, node := range nodes {
+		if !isNodeSchedulable(node) {
+			continue
+		}
+		capacity.Nodes++
+		capacity.Slots += nodeSlots(node, podsByNode[node.Name], request)
+	}
+	return capacity
+}
+
+// nodeSlots is how many more pods with the given requests fit on the node
+func nodeSlots(node *corev1.Node, pods []*corev1.Pod, request corev1.ResourceList) int {
+	free := node.Status.Allocatable.DeepCopy()
+	for _, pod := range pods {
+		for name, used := range podRequests(pod) {
+			if q, ok := free[name]; ok {
+				q.Sub(used)
+				free[name] = q
+			}
+		}
+	}
+
+	slots := int64(-1)
+	if allocatablePods, ok := free[corev1.ResourcePods]; ok {
+		slots = allocatablePods.Value() - int64(len(pods))
+	}
+	for name, want := range request {
+		if want.IsZero() {
+			continue
+		}
+		have := free[name]
+		if n := have.MilliValue() / want.MilliValue(); slots < 0 || n < slots {
+			slots = n
+		}
+	}
+
+	if slots < 0 {
+		return 0
+	}
+	return int(slots)
+}
+
+// podRequests follows the scheduler: containers add up, an init container counts if it asks for more
+func podRequests(pod *corev1.Pod) corev1.ResourceList {
+	total := corev1.ResourceList{}
+	for _, c := range pod.Spec.Containers {
+		addResources(total, c.Resources.Requests)
+	}
+	for _, c := range pod.Spec.InitContainers {
+		for name, q := range c.Resources.Requests {
+			if current, ok := total[name]; !ok || q.Cmp(current) > 0 {
+				total[name] = q.DeepCopy()
+			}
+		}
+	}
+	return total
+}
+
+func addResources(total, add corev1.ResourceList) {
+	for name, q := range add {
+		current := total[name]
+		current.Add(q)
+		total[name] = current
+	}
+}
+
+// isNodeSchedulable leaves out cordoned, not ready and tainted nodes; match pods tolerate no taints
+func isNodeSchedulable(node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute {
+			return false
+		}
+	}
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package k8s
+
+import (
+	"d2c-gs-controller/internal/db"
+	"reflect"
+	"testing"
+
+	"github.com/dota2classic/d2c-go-models/models"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func gameserverNode(name, cpu, memory string) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(memory),
+				corev1.ResourcePods:   resource.MustParse("110"),
+			},
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+func matchPod(node string, region models.Region, cpu, memory string) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: Namespace, Labels: map[string]string{RegionLabel: string(region)}},
+		Spec: corev1.PodSpec{
+			NodeName: node,
+			Containers: []corev1.Container{{
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(cpu),
+					corev1.ResourceMemory: resource.MustParse(memory),
+				}},
+			}},
+		},
+	}
+}
+
+func TestRegionCapacity(t *testing.T) {
+	request := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("500m"),
+		corev1.ResourceMemory: resource.MustParse("512Mi"),
+	}
+
+	cordoned := gameserverNode("cordoned", "4", "8Gi")
+	cordoned.Spec.Unschedulable = true
+
+	cpuBound := gameserverNode("cpu-bound", "2", "8Gi")       // 4 by cpu, 16 by memory
+	memoryBound := gameserverNode("memory-bound", "8", "1Gi") // 2 by memory
+	nodes := []*corev1.Node{&cpuBound, &memoryBound, &cordoned}
+
+	running := matchPod("cpu-bound", models.REGION_RU_MOSCOW, "1", "512Mi")
+	waiting := matchPod("", models.REGION_RU_MOSCOW, "500m", "512Mi")
+	elsewhere := matchPod("", "other", "500m", "512Mi")
+	pods := []*corev1.Pod{&running, &waiting, &elsewhere}
+
+	capacity := regionCapacity(models.REGION_RU_MOSCOW, nodes, pods, request)
+	capacity.FreePorts = 1
+
+	if capacity.Nodes != 2 {
+		t.Errorf("nodes: expected 2, got %d", capacity.Nodes)
+	}
+	if capacity.Slots != 4 {
+		t.Errorf("slots: expected 2 on cpu-bound and 2 on memory-bound, got %d", capacity.Slots)
+	}
+	if capacity.Unscheduled != 1 {
+		t.Errorf("unscheduled: expected 1, got %d", capacity.Unscheduled)
+	}
+	if !capacity.Fits() {
+		t.Errorf("expected a match to fit: %s", capacity)
+	}
+
+	capacity.Reserve()
+	if capacity.Fits() {
+		t.Errorf("expected no free ports after reserving the last pair: %s", capacity)
+	}
+}
+
+func TestNodeSlotsWithoutRequests(t *testing.T) {
+	node := gameserverNode("node", "1", "1Gi")
+	node.Status.Allocatable[corev1.ResourcePods] = resource.MustParse("3")
+	pod := matchPod("node", models.REGION_RU_MOSCOW, "100m", "100Mi")
+
+	if got := nodeSlots(&node, []*corev1.Pod{&pod}, corev1.ResourceList{}); got != 2 {
+		t.Errorf("expected the pod count to limit BestEffort matches to 2, got %d", got)
+	}
+}
+
+func TestJobPodsCarryRegion(t *testing.T) {
+	job, err := createConfiguration[batchv1.Job](RegularJobTemplate, &data)
+	if err != nil {
+		t.Fatalf("Error creating job: %v", err)
+	}
+
+	if got := job.Spec.Template.Labels[RegionLabel]; got != string(data.Region) {
+		t.Errorf("pod template label %s: expected %s, got %s", RegionLabel, data.Region, got)
+	}
+}
+
+func TestMatchRequestsWithArtifactGate(t *testing.T) {
+	settings := &db.GameServerSettings{}
+	without, err := MatchRequests(settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Setenv("ARTIFACTS_ENDPOINT", "minio:9000")
+	t.Setenv("ARTIFACTS_BUCKET", "artifacts")
+	with, err := MatchRequests(settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gate, _ := artifactsResources("")
+	expected := without.Memory().DeepCopy()
+	expected.Add(*gate.Requests.Memory())
+	if with.Memory().Cmp(expected) != 0 {
+		t.Errorf("expected the gate memory to be requested too: %s, got %s", expected.String(), with.Memory().String())
+	}
+}
+
+func TestTrimPod(t *testing.T) {
+	pod := matchPod("node-1", models.REGION_RU_MOSCOW, "500m", "512Mi")
+	pod.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "RCON_PASSWORD", Value: "secret"}}
+	pod.Spec.InitContainers = []corev1.Container{{Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+		corev1.ResourceMemory: resource.MustParse("1Gi"),
+	}}}}
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+
+	obj, err := trimPod(&pod)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	trimmed := obj.(*corev1.Pod)
+
+	if trimmed.Spec.NodeName != "node-1" || trimmed.Labels[RegionLabel] != string(models.REGION_RU_MOSCOW) {
+		t.Errorf("expected node and labels to be kept, got %+v", trimmed)
+	}
+	if trimmed.Spec.Containers[0].Env != nil || trimmed.Status.Conditions != nil {
+		t.Errorf("expected everything the estimate doesn't read to be dropped, got %+v", trimmed)
+	}
+	if want, got := podRequests(&pod), podRequests(trimmed); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected the requests to be kept: %v, got %v", want, got)
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 var (
 	clientset  *kubernetes.Clientset
+	restConfig *rest.Config
 	clientInit sync.Once
 )
 
@@ -47,6 +48,13 @@ func GetClient() *kubernetes.Clientset {
 		}
 
 		clientset = cs
+		restConfig = config
 	})
 	return clientset
 }
+
+// GetConfig returns the config of the client, for requests the clientset can't make on its own like exec
+func GetConfig() *rest.Config {
+	GetClient()
+	return restConfig
+}
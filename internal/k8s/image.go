@@ -0,0 +1,33 @@
+package k8s
+
+import (
+	"d2c-gs-controller/internal/db"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+var ErrNoImageConfigured = errors.New("no gameserver image configured")
+
+// ResolveGameServerImage picks the srcds image for a launch:
+// mode or region override from the resolved settings, then the patch catalog, then the catalog default.
+func ResolveGameServerImage(evt *models.LaunchGameServerCommand, settings *db.GameServerSettings) (string, error) {
+	if settings != nil && settings.Image != "" {
+		log.Printf("Launching match %d on image %s: settings override for mode %d", evt.MatchID, settings.Image, evt.LobbyType)
+		return settings.Image, nil
+	}
+
+	image, err := db.FindImageForPatch(evt.Patch)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && image == "") {
+		return "", fmt.Errorf("%w: patch %s, mode %d", ErrNoImageConfigured, evt.Patch, evt.LobbyType)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("Launching match %d on image %s because received patch was %s", evt.MatchID, image, evt.Patch)
+	return image, nil
+}
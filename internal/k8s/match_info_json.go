@@ -1,6 +1,7 @@
 package k8s
 
 import (
+	"d2c-gs-controller/internal/db"
 	"encoding/json"
 
 	"github.com/dota2classic/d2c-go-models/models"
@@ -18,6 +19,10 @@ type runServerSchema struct {
 	Players      []player               `json:"players"`
 	Patch        models.DotaPatch       `json:"patch"`
 	Region       models.Region          `json:"region"`
+
+	// Set when the match failed over from the region the matchmaker asked for
+	RequestedRegion  models.Region `json:"requestedRegion,omitempty"`
+	LatencyPenaltyMs int           `json:"latencyPenaltyMs,omitempty"`
 }
 
 type player struct {
@@ -30,7 +35,7 @@ type player struct {
 	Team       models.DotaTeam `json:"team"`
 }
 
-func constructMatchInfoJson(command *models.LaunchGameServerCommand) (string, error) {
+func constructMatchInfoJson(command *models.LaunchGameServerCommand, failover *db.Failover) (string, error) {
 
 	strictPause := command.LobbyType != models.MATCHMAKING_MODE_LOBBY && command.GameMode != models.DOTA_GAME_MODE_CAPTAINS_MODE
 
@@ -61,6 +66,11 @@ func constructMatchInfoJson(command *models.LaunchGameServerCommand) (string, er
 		Players:      players,
 	}
 
+	if failover != nil && failover.ToRegion == command.Region {
+		schema.RequestedRegion = failover.RequestedRegion
+		schema.LatencyPenaltyMs = failover.LatencyPenaltyMs
+	}
+
 	res, err := json.Marshal(schema)
 	if err != nil {
 		return "", err
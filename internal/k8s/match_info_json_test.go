@@ -0,0 +1,39 @@
+package k8s
+
+import (
+	"d2c-gs-controller/internal/db"
+	"encoding/json"
+	"testing"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+func TestMatchInfoJsonRecordsFailover(t *testing.T) {
+	cmd := &models.LaunchGameServerCommand{MatchID: 42, Region: models.REGION_RU_MOSCOW}
+	failover := &db.Failover{RequestedRegion: "ru_novosibirsk", ToRegion: models.REGION_RU_MOSCOW, LatencyPenaltyMs: 45}
+
+	raw, err := constructMatchInfoJson(cmd, failover)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var schema runServerSchema
+	if err := json.Unmarshal([]byte(raw), &schema); err != nil {
+		t.Fatalf("invalid match info json: %v", err)
+	}
+	if schema.Region != models.REGION_RU_MOSCOW || schema.RequestedRegion != "ru_novosibirsk" || schema.LatencyPenaltyMs != 45 {
+		t.Errorf("expected Moscow requested as Novosibirsk with 45ms penalty, got %+v", schema)
+	}
+
+	raw, err = constructMatchInfoJson(cmd, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var plain map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &plain); err != nil {
+		t.Fatalf("invalid match info json: %v", err)
+	}
+	if _, ok := plain["requestedRegion"]; ok {
+		t.Errorf("expected no requestedRegion without a failover, got %s", raw)
+	}
+}
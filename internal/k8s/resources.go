@@ -0,0 +1,132 @@
+package k8s
+
+import (
+	"d2c-gs-controller/internal/db"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var ErrInvalidResources = errors.New("invalid gameserver resources")
+
+const (
+	QosGuaranteed = "Guaranteed"
+	QosBurstable  = "Burstable"
+	QosBestEffort = "BestEffort"
+)
+
+// defaultResources are what the job templates hardcoded before resources became settings
+func defaultResources(cpuAffinity bool) (db.ContainerResources, db.ContainerResources) {
+	if cpuAffinity {
+		return db.ContainerResources{CpuRequest: "450m", MemoryRequest: "400Mi"},
+			db.ContainerResources{CpuRequest: "10m", CpuLimit: "10m", MemoryRequest: "30Mi", MemoryLimit: "30Mi"}
+	}
+	return db.ContainerResources{CpuRequest: "300m", MemoryRequest: "350Mi"},
+		db.ContainerResources{CpuRequest: "10m", MemoryRequest: "30Mi"}
+}
+
+// buildResources lays the settings over the template defaults and applies the QoS class.
+// Guaranteed fills missing limits with the requests, BestEffort drops everything.
+func buildResources(settings *db.GameServerSettings) (gameserver corev1.ResourceRequirements, sidecar corev1.ResourceRequirements, err error) {
+	gsDefaults, sidecarDefaults := defaultResources(settings.CpuAffinity)
+	gsConfig := overlayResources(gsDefaults, settings.Resources)
+	sidecarConfig := overlayResources(sidecarDefaults, settings.SidecarResources)
+
+	gameserver, err = containerResources("gameserver", gsConfig, settings.QosClass)
+	if err != nil {
+		return
+	}
+	sidecar, err = containerResources("sidecar", sidecarConfig, settings.QosClass)
+	return
+}
+
+func overlayResources(base, override db.ContainerResources) db.ContainerResources {
+	if override.CpuRequest != "" {
+		base.CpuRequest = override.CpuRequest
+	}
+	if override.CpuLimit != "" {
+		base.CpuLimit = override.CpuLimit
+	}
+	if override.MemoryRequest != "" {
+		base.MemoryRequest = override.MemoryRequest
+	}
+	if override.MemoryLimit != "" {
+		base.MemoryLimit = override.MemoryLimit
+	}
+	return base
+}
+
+func containerResources(container string, config db.ContainerResources, qos string) (corev1.ResourceRequirements, error) {
+	req := corev1.ResourceRequirements{}
+
+	switch qos {
+	case QosBestEffort:
+		return req, nil
+	case QosGuaranteed:
+		if config.CpuRequest == "" || config.MemoryRequest == "" {
+			return req, fmt.Errorf("%w: %s needs cpu and memory requests for %s", ErrInvalidResources, container, qos)
+		}
+		if config.CpuLimit == "" {
+			config.CpuLimit = config.CpuRequest
+		}
+		if config.MemoryLimit == "" {
+			config.MemoryLimit = config.MemoryRequest
+		}
+	case "", QosBurstable:
+	default:
+		return req, fmt.Errorf("%w: unknown QoS class %q", ErrInvalidResources, qos)
+	}
+
+	for _, r := range []struct {
+		name    corev1.ResourceName
+		request string
+		limit   string
+	}{
+		{corev1.ResourceCPU, config.CpuRequest, config.CpuLimit},
+		{corev1.ResourceMemory, config.MemoryRequest, config.MemoryLimit},
+	} {
+		request, err := parseQuantity(container, r.name, "request", r.request)
+		if err != nil {
+			return req, err
+		}
+		limit, err := parseQuantity(container, r.name, "limit", r.limit)
+		if err != nil {
+			return req, err
+		}
+
+		if request != nil && limit != nil && limit.Cmp(*request) < 0 {
+			return req, fmt.Errorf("%w: %s %s limit %s is below request %s", ErrInvalidResources, container, r.name, r.limit, r.request)
+		}
+		if qos == QosGuaranteed && limit.Cmp(*request) != 0 {
+			return req, fmt.Errorf("%w: %s %s limit %s must equal request %s for %s", ErrInvalidResources, container, r.name, r.limit, r.request, qos)
+		}
+
+		if request != nil {
+			if req.Requests == nil {
+				req.Requests = corev1.ResourceList{}
+			}
+			req.Requests[r.name] = *request
+		}
+		if limit != nil {
+			if req.Limits == nil {
+				req.Limits = corev1.ResourceList{}
+			}
+			req.Limits[r.name] = *limit
+		}
+	}
+
+	return req, nil
+}
+
+func parseQuantity(container string, name corev1.ResourceName, kind, value string) (*resource.Quantity, error) {
+	if value == "" {
+		return nil, nil
+	}
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s %s %s %q: %v", ErrInvalidResources, container, name, kind, value, err)
+	}
+	return &q, nil
+}
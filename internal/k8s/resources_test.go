@@ -0,0 +1,96 @@
+package k8s
+
+import (
+	"d2c-gs-controller/internal/db"
+	"errors"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestBuildResourcesDefaults(t *testing.T) {
+	gameserver, sidecar, err := buildResources(&db.GameServerSettings{CpuAffinity: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := gameserver.Requests.Cpu().String(); got != "450m" {
+		t.Errorf("gameserver cpu request: expected 450m, got %s", got)
+	}
+	if gameserver.Limits != nil {
+		t.Errorf("gameserver limits: expected none, got %v", gameserver.Limits)
+	}
+	if got := sidecar.Limits.Memory().String(); got != "30Mi" {
+		t.Errorf("sidecar memory limit: expected 30Mi, got %s", got)
+	}
+}
+
+func TestBuildResourcesGuaranteed(t *testing.T) {
+	settings := &db.GameServerSettings{
+		Resources: db.ContainerResources{CpuRequest: "1", MemoryRequest: "512Mi"},
+		QosClass:  QosGuaranteed,
+	}
+
+	data := data
+	var err error
+	data.GameServerResources, data.SidecarResources, err = buildResources(settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	job, err := createConfiguration[batchv1.Job](CpuAffinityJobTemplate, &data)
+	if err != nil {
+		t.Fatalf("Error creating job: %v", err)
+	}
+
+	assertQosGuaranteed(t, &job.Spec.Template.Spec.Containers[0], true)
+	assertQosGuaranteed(t, &job.Spec.Template.Spec.Containers[1], true)
+	assertCpuAffinity(t, &job.Spec.Template.Spec.Containers[1], true)
+}
+
+func TestBuildResourcesValidation(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings db.GameServerSettings
+	}{
+		{"limit below request", db.GameServerSettings{Resources: db.ContainerResources{CpuRequest: "500m", CpuLimit: "400m"}}},
+		{"bad quantity", db.GameServerSettings{SidecarResources: db.ContainerResources{MemoryRequest: "lots"}}},
+		{"guaranteed with different limit", db.GameServerSettings{Resources: db.ContainerResources{MemoryLimit: "1Gi"}, QosClass: QosGuaranteed}},
+		{"unknown qos", db.GameServerSettings{QosClass: "Premium"}},
+	}
+
+	for _, c := range cases {
+		_, _, err := buildResources(&c.settings)
+		if !errors.Is(err, ErrInvalidResources) {
+			t.Errorf("%s: expected ErrInvalidResources, got %v", c.name, err)
+		}
+	}
+}
+
+func TestArtifactsResources(t *testing.T) {
+	burstable, err := artifactsResources("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := burstable.Requests.Cpu().String(); got != "10m" {
+		t.Errorf("gate cpu request: expected 10m, got %s", got)
+	}
+	if _, ok := burstable.Limits[corev1.ResourceCPU]; ok {
+		t.Errorf("gate cpu limit: expected none, got %v", burstable.Limits)
+	}
+
+	guaranteed, err := artifactsResources(QosGuaranteed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertQosGuaranteed(t, &corev1.Container{Resources: guaranteed}, true)
+
+	bestEffort, err := artifactsResources(QosBestEffort)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bestEffort.Requests != nil || bestEffort.Limits != nil {
+		t.Errorf("gate resources: expected none for BestEffort, got %v", bestEffort)
+	}
+}
@@ -0,0 +1,49 @@
+package k8s
+
+import (
+	"context"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const rollbackTimeout = 10 * time.Second
+
+// RollbackDeployment deletes whatever part of a match deployment exists, after a failed launch or once the match is over.
+// It uses its own timeout, so it still runs when the launch context is already cancelled.
+func RollbackDeployment(clientset *kubernetes.Clientset, matchId int64, deployed *DeployedMatch) {
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+
+	log.Printf("Deleting deployment of match %d", matchId)
+
+	deletePolicy := metav1.DeletePropagationBackground
+
+	if deployed.JobName != "" {
+		err := clientset.BatchV1().Jobs(Namespace).Delete(ctx, deployed.JobName, metav1.DeleteOptions{
+			PropagationPolicy: &deletePolicy,
+		})
+		LogDeleteError("Job", deployed.JobName, err)
+	}
+
+	if deployed.ConfigMapName != "" {
+		err := clientset.CoreV1().ConfigMaps(Namespace).Delete(ctx, deployed.ConfigMapName, metav1.DeleteOptions{})
+		LogDeleteError("ConfigMap", deployed.ConfigMapName, err)
+	}
+
+	if deployed.SecretName != "" {
+		err := clientset.CoreV1().Secrets(Namespace).Delete(ctx, deployed.SecretName, metav1.DeleteOptions{})
+		LogDeleteError("Secret", deployed.SecretName, err)
+	}
+}
+
+// LogDeleteError logs a failed delete; objects that are already gone are fine
+func LogDeleteError(kind, name string, err error) {
+	if err != nil && !k8serrors.IsNotFound(err) {
+		log.Printf("Failed to delete %s %s: %v", kind, name, err)
+	}
+}
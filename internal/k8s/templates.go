@@ -2,9 +2,11 @@ package k8s
 
 import (
 	"bytes"
+	"encoding/json"
 	"text/template"
 
 	"github.com/dota2classic/d2c-go-models/models"
+	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/yaml"
 
 	_ "embed"
@@ -22,6 +24,9 @@ var CpuAffinityJobTemplate string
 //go:embed templates/regular-job.template.yaml
 var RegularJobTemplate string
 
+//go:embed templates/warm-job.template.yaml
+var WarmJobTemplate string
+
 //const (
 //	SECRET_TEMPLATE    = "./templates/secret.template.yaml"
 //	CONFIGMAP_TEMPLATE = "./templates/configmap.template.yaml"
@@ -30,6 +35,7 @@ var RegularJobTemplate string
 
 type templateData struct {
 	MatchId         int64
+	WarmServerId    int64 // warm pool jobs only, they have no match yet
 	GameMode        models.DotaGameMode
 	LobbyType       models.MatchmakingMode
 	Map             models.DotaMap
@@ -53,10 +59,24 @@ type templateData struct {
 	AbandonHighQuality int
 
 	BotDifficulty int
+
+	GameServerResources corev1.ResourceRequirements
+	SidecarResources    corev1.ResourceRequirements
+
+	ArtifactsHoldSeconds int // 0 leaves the artifacts container out, see artifacts_gate.go
+	ArtifactsResources   corev1.ResourceRequirements
+}
+
+var templateFuncs = template.FuncMap{
+	// JSON is valid YAML, so structured values can be inlined
+	"toJson": func(v interface{}) (string, error) {
+		out, err := json.Marshal(v)
+		return string(out), err
+	},
 }
 
 func createConfiguration[T any](templateContent string, data *templateData) (*T, error) {
-	tmpl, err := template.New("tmpl").Parse(templateContent)
+	tmpl, err := template.New("tmpl").Funcs(templateFuncs).Parse(templateContent)
 	if err != nil {
 		return nil, err
 	}
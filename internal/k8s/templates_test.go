@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/dota2classic/d2c-go-models/models"
@@ -38,6 +39,8 @@ func TestCreateConfigmap(t *testing.T) {
 	if cfg.ObjectMeta.Name != configName {
 		t.Errorf("Resource name mismatch. Expected %s, got %s", configName, cfg.Name)
 	}
+
+	assertGameserverLabels(t, cfg.Labels)
 }
 
 func TestCreateSecret(t *testing.T) {
@@ -55,6 +58,8 @@ func TestCreateSecret(t *testing.T) {
 	if cfg.Name != secretName {
 		t.Errorf("Resource name mismatch. Expected %s, got %s", secretName, cfg.Name)
 	}
+
+	assertGameserverLabels(t, cfg.Labels)
 }
 
 func TestCreateCpuAffinityJob(t *testing.T) {
@@ -69,6 +74,8 @@ func TestCreateCpuAffinityJob(t *testing.T) {
 		t.Errorf("Resource name mismatch. Expected %s, got %s", jobName, job.Name)
 	}
 
+	assertGameserverLabels(t, job.Labels)
+
 	// Should only run on gameserver nodes with selected region
 	// Should prefer cpuAffinity nodes
 	assertNodeAffinity(t, job.Spec.Template.Spec.Affinity,
@@ -110,6 +117,8 @@ func TestCreateRegularJob(t *testing.T) {
 		t.Errorf("Resource name mismatch. Expected %s, got %s", jobName, job.Name)
 	}
 
+	assertGameserverLabels(t, job.Labels)
+
 	// Should only run on gameserver nodes with selected region
 	// Should prefer non-cpuAffinity nodes
 	assertNodeAffinity(t, job.Spec.Template.Spec.Affinity,
@@ -246,6 +255,17 @@ func assertNodeAffinity(t *testing.T, affinity *corev1.Affinity, expectedRequire
 	//}
 }
 
+func assertGameserverLabels(t *testing.T, labels map[string]string) {
+	t.Helper()
+
+	if labels["app-type"] != "gameserver" {
+		t.Errorf("Label app-type mismatch. Expected gameserver, got %s", labels["app-type"])
+	}
+	if labels["ru.dotaclassic/matchId"] != strconv.FormatInt(data.MatchId, 10) {
+		t.Errorf("Label ru.dotaclassic/matchId mismatch. Expected %d, got %s", data.MatchId, labels["ru.dotaclassic/matchId"])
+	}
+}
+
 // helper for checking slice
 func contains(slice []string, val string) bool {
 	for _, v := range slice {
@@ -261,3 +281,92 @@ func assertImage(t *testing.T, container *corev1.Container, image string) {
 		t.Errorf("Container image mismatch. Expected %s, got %s", image, container.Image)
 	}
 }
+
+func TestCreateWarmJob(t *testing.T) {
+	warm := data
+	warm.WarmServerId = 7
+	warm.HostGamePort = 30100
+	warm.HostSourceTVPort = 30101
+
+	job, err := createConfiguration[batchv1.Job](WarmJobTemplate, &warm)
+	if err != nil {
+		t.Fatalf("Error creating job: %v", err)
+	}
+
+	if job.Name != "gameserver-warm-job-7" {
+		t.Errorf("Resource name mismatch. Expected gameserver-warm-job-7, got %s", job.Name)
+	}
+	if job.Labels[WarmServerLabel] != "7" || job.Spec.Template.Labels[WarmServerLabel] != "7" {
+		t.Errorf("Warm server label missing on job or pod template")
+	}
+	// Reconcile and the pod informer must not see the job before it is claimed
+	if _, ok := job.Spec.Template.Labels[matchIdLabel]; ok {
+		t.Errorf("Warm job must not carry a match id")
+	}
+
+	sidecar := &job.Spec.Template.Spec.Containers[0]
+	checkEnvVar(t, sidecar, "WARM_POOL", "1")
+	checkEnvVar(t, sidecar, "HOST_PORT", "30100")
+
+	gameserver := &job.Spec.Template.Spec.Containers[1]
+	assertImage(t, gameserver, data.GameServerImage)
+	if gameserver.Ports[0].HostPort != 30100 || gameserver.Ports[2].HostPort != 30101 {
+		t.Errorf("Host ports mismatch: %+v", gameserver.Ports)
+	}
+}
+
+func TestArtifactGate(t *testing.T) {
+	for _, tmpl := range []string{RegularJobTemplate, CpuAffinityJobTemplate, WarmJobTemplate} {
+		job, err := createConfiguration[batchv1.Job](tmpl, &data)
+		if err != nil {
+			t.Fatalf("Error creating job: %v", err)
+		}
+		if findContainer(job, ArtifactsContainer) != nil {
+			t.Errorf("Job %s must not have an artifact gate without storage", job.Name)
+		}
+
+		gated := data
+		gated.ArtifactsHoldSeconds = 900
+		gated.ArtifactsResources, err = artifactsResources(QosGuaranteed)
+		if err != nil {
+			t.Fatalf("Error building gate resources: %v", err)
+		}
+		job, err = createConfiguration[batchv1.Job](tmpl, &gated)
+		if err != nil {
+			t.Fatalf("Error creating gated job: %v", err)
+		}
+
+		gate := findContainer(job, ArtifactsContainer)
+		if gate == nil {
+			t.Fatalf("Job %s has no artifact gate", job.Name)
+		}
+		mounts := map[string]string{}
+		for _, m := range gate.VolumeMounts {
+			mounts[m.Name] = m.MountPath
+		}
+		expected := map[string]string{
+			"logs":           ArtifactsDir + "/logs",
+			"replays":        ArtifactsDir + "/replays",
+			"dumps":          ArtifactsDir + "/dumps",
+			"artifacts-gate": "/gate",
+		}
+		if !reflect.DeepEqual(mounts, expected) {
+			t.Errorf("Job %s gate mounts mismatch: %v", job.Name, mounts)
+		}
+		if !reflect.DeepEqual(gate.Resources, gated.ArtifactsResources) {
+			t.Errorf("Job %s gate resources mismatch: %v", job.Name, gate.Resources)
+		}
+		if len(gate.Command) != 3 || !strings.Contains(gate.Command[2], ArtifactsGateFile) || !strings.Contains(gate.Command[2], "-ge 900") {
+			t.Errorf("Job %s gate must wait for %s at most 900s: %v", job.Name, ArtifactsGateFile, gate.Command)
+		}
+	}
+}
+
+func findContainer(job *batchv1.Job, name string) *corev1.Container {
+	for i := range job.Spec.Template.Spec.Containers {
+		if job.Spec.Template.Spec.Containers[i].Name == name {
+			return &job.Spec.Template.Spec.Containers[i]
+		}
+	}
+	return nil
+}
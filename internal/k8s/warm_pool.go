@@ -0,0 +1,239 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"d2c-gs-controller/internal/db"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	WarmServerLabel = "ru.dotaclassic/warmServer"
+	matchIdLabel    = "ru.dotaclassic/matchId"
+
+	sidecarPort    = 7777
+	warmAssignPath = "/warm/assign"
+	assignTimeout  = 10 * time.Second
+)
+
+/**
+Warm assignment contract with dota2classic/srcds-sidecar, started with WARM_POOL=1 (warm-job.template.yaml):
+- the controller sends POST http://<pod ip>:7777/warm/assign with a JSON warmAssignment once a match claims the pod
+- the sidecar writes match.json and the match env for the gameserver, then lets srcds load the match
+- it answers 2xx only after that; any other status, or no answer within assignTimeout, rolls the claim back
+  and the match starts cold
+- a pod accepts one assignment; the pool never sends a second one to the same pod
+*/
+
+// warmAssignment is the body of POST /warm/assign. It carries what a cold pod gets from its env and
+// match.json at creation.
+type warmAssignment struct {
+	MatchId            int64                  `json:"matchId"`
+	LobbyType          models.MatchmakingMode `json:"lobbyType"`
+	GameMode           models.DotaGameMode    `json:"gameMode"`
+	Map                models.DotaMap         `json:"map"`
+	Match              json.RawMessage        `json:"match"`
+	TickRate           int                    `json:"tickRate"`
+	ConfigName         string                 `json:"configName"`
+	LoadTimeout        int                    `json:"loadTimeout"`
+	LogFileName        string                 `json:"logFileName"`
+	EnableBans         int                    `json:"enableBans"`
+	DisableRunes       int                    `json:"disableRunes"`
+	MidTowerToWin      int                    `json:"midTowerToWin"`
+	KillsToWin         int                    `json:"killsToWin"`
+	AbandonHighQuality int                    `json:"abandonHighQuality"`
+	BotDifficulty      int                    `json:"botDifficulty"`
+}
+
+// PlanWarmServer leases ports for an idle pool server and renders its objects.
+// On error the caller deletes the warm server, which gives the ports back.
+func PlanWarmServer(ws *db.WarmServer) (*MatchPlan, error) {
+	gsPort, tvPort, err := db.LeasePorts(ws.LeaseId(), ws.Region)
+	if err != nil {
+		log.Printf("Error allocating ports for warm server %d: %v", ws.Id, err)
+		return nil, err
+	}
+
+	// Warm servers run on the template defaults; modes with their own resources start cold
+	gsResources, sidecarResources, err := buildResources(&db.GameServerSettings{})
+	if err != nil {
+		return nil, err
+	}
+	artifactsResources, err := artifactsResources("")
+	if err != nil {
+		return nil, err
+	}
+
+	data := &templateData{
+		WarmServerId:     ws.Id,
+		Region:           ws.Region,
+		RconPassword:     generateRconPassword(),
+		MatchJson:        "{}",
+		GameServerImage:  ws.Image,
+		HostGamePort:     gsPort,
+		HostSourceTVPort: tvPort,
+
+		GameServerResources: gsResources,
+		SidecarResources:    sidecarResources,
+
+		ArtifactsHoldSeconds: artifactsHoldSeconds(),
+		ArtifactsResources:   artifactsResources,
+	}
+
+	configMap, err := createConfiguration[corev1.ConfigMap](ConfigmapTemplate, data)
+	if err != nil {
+		log.Printf("Error rendering ConfigMap: %v", err)
+		return nil, err
+	}
+
+	secret, err := createConfiguration[corev1.Secret](SecretTemplate, data)
+	if err != nil {
+		log.Printf("Error rendering Secret: %v", err)
+		return nil, err
+	}
+
+	job, err := createConfiguration[batchv1.Job](WarmJobTemplate, data)
+	if err != nil {
+		log.Printf("Error rendering Job: %v", err)
+		return nil, err
+	}
+
+	// The config templates are named after the match, a warm server has none yet
+	labels := map[string]string{
+		"app-type":      "gameserver",
+		WarmServerLabel: strconv.FormatInt(ws.Id, 10),
+	}
+	configMap.Name = ws.ConfigMapName
+	configMap.Labels = labels
+	secret.Name = ws.SecretName
+	secret.Labels = labels
+
+	return &MatchPlan{
+		MatchId:   ws.LeaseId(),
+		Region:    ws.Region,
+		ConfigMap: configMap,
+		Secret:    secret,
+		Job:       job,
+	}, nil
+}
+
+// AssignWarmServer hands a claimed warm server its match. The sidecar gets the config first; then the
+// job, pod, Secret and ConfigMap are labelled with the match id, so the informers and reconcile pick them up.
+func AssignWarmServer(ctx context.Context, clientset *kubernetes.Clientset, ws *db.WarmServer, evt *models.LaunchGameServerCommand, settings *db.GameServerSettings) error {
+	data, err := newMatchTemplateData(evt, settings)
+	if err != nil {
+		return err
+	}
+
+	pods, err := clientset.CoreV1().Pods(Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", ws.JobName),
+	})
+	if err != nil {
+		return err
+	}
+
+	var pod *corev1.Pod
+	for i := range pods.Items {
+		if pods.Items[i].Status.Phase == corev1.PodRunning && pods.Items[i].Status.PodIP != "" {
+			pod = &pods.Items[i]
+			break
+		}
+	}
+	if pod == nil {
+		return fmt.Errorf("warm server %d has no running pod", ws.Id)
+	}
+
+	if err := postAssignment(ctx, pod.Status.PodIP, newWarmAssignment(data)); err != nil {
+		return fmt.Errorf("warm server %d refused match %d: %w", ws.Id, evt.MatchID, err)
+	}
+
+	labels := map[string]interface{}{
+		"labels": map[string]string{matchIdLabel: strconv.FormatInt(evt.MatchID, 10)},
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": labels})
+	if err != nil {
+		return err
+	}
+
+	if _, err := clientset.BatchV1().Jobs(Namespace).Patch(ctx, ws.JobName, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return err
+	}
+	if _, err := clientset.CoreV1().Pods(Namespace).Patch(ctx, pod.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return err
+	}
+	// The sweeper skips warm objects without a match id, so the Secret needs it too
+	if _, err := clientset.CoreV1().Secrets(Namespace).Patch(ctx, ws.SecretName, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return err
+	}
+
+	// Keep match.json in the ConfigMap, like on a cold launch
+	configPatch, err := json.Marshal(map[string]interface{}{
+		"metadata": labels,
+		"data":     map[string]string{"match.json": data.MatchJson},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = clientset.CoreV1().ConfigMaps(Namespace).Patch(ctx, ws.ConfigMapName, types.MergePatchType, configPatch, metav1.PatchOptions{})
+	return err
+}
+
+func newWarmAssignment(data *templateData) *warmAssignment {
+	return &warmAssignment{
+		MatchId:            data.MatchId,
+		LobbyType:          data.LobbyType,
+		GameMode:           data.GameMode,
+		Map:                data.Map,
+		Match:              json.RawMessage(data.MatchJson),
+		TickRate:           data.TickRate,
+		ConfigName:         data.ConfigName,
+		LoadTimeout:        data.LoadTimeout,
+		LogFileName:        fmt.Sprintf("%d.log", data.MatchId),
+		EnableBans:         data.EnableBans,
+		DisableRunes:       data.DisableRunes,
+		MidTowerToWin:      data.MidTowerToWin,
+		KillsToWin:         data.KillsToWin,
+		AbandonHighQuality: data.AbandonHighQuality,
+		BotDifficulty:      data.BotDifficulty,
+	}
+}
+
+func postAssignment(ctx context.Context, podIP string, assignment *warmAssignment) error {
+	body, err := json.Marshal(assignment)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, assignTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("http://%s:%d%s", podIP, sidecarPort, warmAssignPath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sidecar answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package leader
+
+import (
+	"context"
+	"d2c-gs-controller/internal/util"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+/**
+Only one replica may reconcile jobs, watch heartbeats and clean up.
+Everything else (consuming launch commands, health server) runs on every replica.
+
+LEADER_ELECTION selects the lock:
+- kubernetes (default): a coordination.k8s.io Lease
+- redis: a SET NX key with a TTL, for local runs outside the cluster
+- none: this replica always leads
+*/
+
+const (
+	ModeKubernetes = "kubernetes"
+	ModeRedis      = "redis"
+	ModeNone       = "none"
+
+	lockName = "gs-controller-leader"
+)
+
+type config struct {
+	identity      string
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+}
+
+func loadConfig() config {
+	return config{
+		identity:      identity(),
+		leaseDuration: util.GetEnvDuration("LEADER_LEASE_DURATION", "15s"),
+		renewDeadline: util.GetEnvDuration("LEADER_RENEW_DEADLINE", "10s"),
+		retryPeriod:   util.GetEnvDuration("LEADER_RETRY_PERIOD", "2s"),
+	}
+}
+
+func identity() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "gs-controller"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// Run blocks forever and calls lead every time this replica becomes leader.
+// The context passed to lead is cancelled as soon as leadership is lost.
+func Run(ctx context.Context, lead func(ctx context.Context)) {
+	cfg := loadConfig()
+	mode := os.Getenv("LEADER_ELECTION")
+	if mode == "" {
+		mode = ModeKubernetes
+	}
+
+	log.Printf("Leader election mode %s, identity %s", mode, cfg.identity)
+
+	for ctx.Err() == nil {
+		switch mode {
+		case ModeKubernetes:
+			runKubernetes(ctx, cfg, lead)
+		case ModeRedis:
+			runRedis(ctx, cfg, lead)
+		case ModeNone:
+			lead(ctx)
+		default:
+			log.Fatalf("Invalid LEADER_ELECTION: %s", mode)
+		}
+
+		if ctx.Err() == nil {
+			time.Sleep(cfg.retryPeriod)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package leader
+
+import (
+	"context"
+	"d2c-gs-controller/internal/k8s"
+	"log"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// runKubernetes returns once leadership is lost or ctx is done
+func runKubernetes(ctx context.Context, cfg config, lead func(ctx context.Context)) {
+	namespace := os.Getenv("LEADER_ELECTION_NAMESPACE")
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      lockName,
+			Namespace: namespace,
+		},
+		Client: k8s.GetClient().CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: cfg.identity,
+		},
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   cfg.leaseDuration,
+		RenewDeadline:   cfg.renewDeadline,
+		RetryPeriod:     cfg.retryPeriod,
+		ReleaseOnCancel: true,
+		Name:            lockName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				log.Printf("Became leader")
+				lead(ctx)
+			},
+			OnStoppedLeading: func() {
+				log.Printf("Lost leadership")
+			},
+			OnNewLeader: func(identity string) {
+				if identity != cfg.identity {
+					log.Printf("Current leader is %s", identity)
+				}
+			},
+		},
+	})
+	if err != nil {
+		log.Fatalf("Failed to create leader elector: %v", err)
+	}
+
+	elector.Run(ctx)
+}
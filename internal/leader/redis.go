@@ -0,0 +1,87 @@
+package leader
+
+import (
+	"context"
+	"d2c-gs-controller/internal/redis"
+	"log"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const redisLockKey = "lock:" + lockName
+
+// renewScript extends the lock only while we still own it
+var renewScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript deletes the lock only while we still own it
+var releaseScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// runRedis returns once leadership is lost or ctx is done
+func runRedis(ctx context.Context, cfg config, lead func(ctx context.Context)) {
+	for {
+		acquired, err := redis.Client.SetNX(ctx, redisLockKey, cfg.identity, cfg.leaseDuration).Result()
+		if err != nil {
+			log.Printf("Failed to acquire leader lock: %v", err)
+		}
+		if acquired {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cfg.retryPeriod):
+		}
+	}
+
+	log.Printf("Became leader")
+	leadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		defer cancel()
+		ticker := time.NewTicker(cfg.retryPeriod)
+		defer ticker.Stop()
+
+		lastRenew := time.Now()
+		for {
+			select {
+			case <-leadCtx.Done():
+				return
+			case <-ticker.C:
+				renewed, err := renewScript.Run(leadCtx, redis.Client, []string{redisLockKey}, cfg.identity, cfg.leaseDuration.Milliseconds()).Int()
+				if err == nil && renewed == 1 {
+					lastRenew = time.Now()
+					continue
+				}
+				if err == nil {
+					log.Printf("Leader lock taken over by another replica")
+					return
+				}
+				log.Printf("Failed to renew leader lock: %v", err)
+				if time.Since(lastRenew) > cfg.renewDeadline {
+					return
+				}
+			}
+		}
+	}()
+
+	lead(leadCtx)
+	cancel()
+
+	releaseCtx, releaseCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer releaseCancel()
+	_ = releaseScript.Run(releaseCtx, redis.Client, []string{redisLockKey}, cfg.identity).Err()
+	log.Printf("Lost leadership")
+}
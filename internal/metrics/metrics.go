@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "gs_controller"
+
+var (
+	Launches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "launches_total",
+		Help:      "LaunchGameServerCommand messages handled, by region and result.",
+	}, []string{"region", "result"})
+
+	LaunchFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "launch_failures_total",
+		Help:      "Launches that failed, by region and reason.",
+	}, []string{"region", "reason"})
+
+	DeployDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "deploy_duration_seconds",
+		Help:      "Time spent creating the ConfigMap, Secret and Job of a match.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"region"})
+
+	LaunchLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "launch_latency_seconds",
+		Help:      "Time from the launch command to the gameserver running.",
+		Buckets:   []float64{5, 10, 15, 20, 30, 45, 60, 90, 120, 180, 300},
+	}, []string{"region"})
+
+	PendingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "pending_duration_seconds",
+		Help:      "Time a match spent pending before the pod was scheduled.",
+		Buckets:   []float64{1, 2, 5, 10, 20, 30, 60, 120},
+	}, []string{"region"})
+
+	StatusTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "status_transitions_total",
+		Help:      "Match status changes, by old and new status.",
+	}, []string{"from", "to"})
+
+	PortLeases = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "port_leases",
+		Help:      "Host port pairs currently leased, by region.",
+	}, []string{"region"})
+
+	PortPoolSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "port_pool_size",
+		Help:      "Host port pairs available to each region.",
+	})
+
+	HeartbeatTimeouts = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "heartbeat_timeouts_total",
+		Help:      "Gameservers marked dead because their heartbeat went stale.",
+	})
+
+	UnhealthyMatches = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "unhealthy_matches",
+		Help:      "Matches running in Kubernetes without a heartbeat past the grace period.",
+	})
+
+	RogueServers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "rogue_servers",
+		Help:      "Gameservers sending heartbeats for a match without a match_resources row.",
+	})
+
+	SilentMatchKills = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "silent_match_kills_total",
+		Help:      "Unhealthy matches killed by the heartbeat monitor.",
+	})
+
+	WarmServers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "warm_servers",
+		Help:      "Idle warm pool servers, by region, patch and state (starting or ready).",
+	}, []string{"region", "patch", "state"})
+
+	WarmClaims = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "warm_claims_total",
+		Help:      "Launches that tried the warm pool, by region and result (hit, miss or error).",
+	}, []string{"region", "result"})
+
+	AdmissionDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "admission_decisions_total",
+		Help:      "Launches checked against region capacity, by region and decision (admitted, failover, rejected, queued, released or expired).",
+	}, []string{"region", "decision"})
+
+	LaunchQueueLength = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "launch_queue_length",
+		Help:      "Launches waiting for capacity, by region.",
+	}, []string{"region"})
+
+	Failovers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "failovers_total",
+		Help:      "Matches moved to a fallback region, by requested and fallback region.",
+	}, []string{"from", "to"})
+
+	OrphanedObjects = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "orphaned_objects",
+		Help:      "Gameserver objects without a match_resources row found by the last sweep, by kind.",
+	}, []string{"kind"})
+
+	OrphanSweeps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orphan_sweeps_total",
+		Help:      "Orphaned objects deleted or adopted by the sweeper, by kind and action.",
+	}, []string{"kind", "action"})
+
+	RconCommands = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rcon_commands_total",
+		Help:      "RCON commands proxied to gameservers, by source and result (ok, rejected, failed).",
+	}, []string{"source", "result"})
+
+	ArtifactUploads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "artifact_uploads_total",
+		Help:      "Replays, logs and dumps uploaded from finishing matches, by kind and result.",
+	}, []string{"kind", "result"})
+)
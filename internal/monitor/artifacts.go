@@ -0,0 +1,144 @@
+package monitor
+
+import (
+	"context"
+	"d2c-gs-controller/internal/artifacts"
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/k8s"
+	"d2c-gs-controller/internal/util"
+	"log"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+/**
+Replays, logs and dumps live in emptyDir volumes and go away with the pod. The artifacts container of the pod
+(see k8s/artifacts_gate.go) holds them after the gameserver exits, so the match stays finishing until they are
+copied out and uploaded (see artifacts.Collect). Then the controller opens the gate and the pod completes.
+A failed upload is tried again on the next reconcile, until the gate is about to time out.
+Teardown of a done or failed match waits for that. A pod that completed without the gate being opened, because
+ARTIFACTS_HOLD_TIMEOUT passed or it predates the gate, has nothing left to copy and is torn down right away.
+The copy runs tar in the pod, so the controller's service account needs create on pods/exec.
+*/
+
+type artifactsStep int
+
+const (
+	artifactsDone    artifactsStep = iota // collected or not wanted, teardown may go ahead
+	artifactsWait                         // the gameserver still runs or the upload is in flight
+	artifactsCollect                      // the gameserver exited and the gate holds the volumes
+	artifactsMissed                       // the pod is gone past the gate, nothing left to copy
+)
+
+// collecting holds the matches whose artifacts are being uploaded right now
+var collecting sync.Map
+
+// artifactsCollected tells whether teardown may go ahead and starts the collection once it can
+func artifactsCollected(mr *db.MatchResources, pods []*corev1.Pod) bool {
+	_, busy := collecting.Load(mr.MatchId)
+	step, pod := nextArtifactsStep(k8s.ArtifactsEnabled(), mr, busy, pods)
+
+	switch step {
+	case artifactsCollect:
+		collecting.Store(mr.MatchId, true)
+		go collectArtifacts(mr.MatchId, pod)
+		return false
+	case artifactsWait:
+		return false
+	case artifactsMissed:
+		log.Printf("Pod of match %d completed without its artifacts being collected", mr.MatchId)
+		markArtifactsCollected(mr.MatchId)
+	}
+	return true
+}
+
+func nextArtifactsStep(enabled bool, mr *db.MatchResources, busy bool, pods []*corev1.Pod) (artifactsStep, *corev1.Pod) {
+	if !enabled || mr.ArtifactsCollectedAt != nil {
+		return artifactsDone, nil
+	}
+	if busy {
+		return artifactsWait, nil
+	}
+
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || !isGateHolding(pod) {
+			continue
+		}
+		if gameserverExited(pod) {
+			return artifactsCollect, pod
+		}
+		return artifactsWait, nil
+	}
+	return artifactsMissed, nil
+}
+
+func collectArtifacts(matchId int64, pod *corev1.Pod) {
+	defer collecting.Delete(matchId)
+
+	timeout := util.GetEnvDuration("ARTIFACTS_TIMEOUT", "2m")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	uploaded, err := artifacts.Collect(ctx, pod, matchId)
+	log.Printf("Uploaded %d artifacts of match %d", len(uploaded), matchId)
+	if err != nil {
+		// The gate keeps the volumes, so the next reconcile tries again while there is time for another attempt
+		if !gateExpiring(pod, time.Now(), k8s.ArtifactsHoldTimeout(), timeout) {
+			log.Printf("Artifacts of match %d collected with errors, retrying: %v", matchId, err)
+			return
+		}
+		log.Printf("Artifacts of match %d collected with errors and the gate is about to time out: %v", matchId, err)
+	}
+
+	// Marked before the gate opens, so the completed pod isn't mistaken for a missed one
+	markArtifactsCollected(matchId)
+
+	releaseCtx, cancelRelease := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelRelease()
+	if err := artifacts.ReleaseGate(releaseCtx, pod); err != nil {
+		log.Printf("Failed to open the artifact gate of match %d, the pod goes at ARTIFACTS_HOLD_TIMEOUT: %v", matchId, err)
+	}
+}
+
+// gateExpiring is true when the gate lets the pod go before another attempt of the given length could finish
+func gateExpiring(pod *corev1.Pod, now time.Time, hold, attempt time.Duration) bool {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == k8s.ArtifactsContainer && cs.State.Running != nil {
+			return now.Add(attempt).After(cs.State.Running.StartedAt.Add(hold))
+		}
+	}
+	return true
+}
+
+func markArtifactsCollected(matchId int64) {
+	if err := db.MarkArtifactsCollected(matchId); err != nil {
+		log.Printf("Failed to mark artifacts of match %d collected: %v", matchId, err)
+	}
+}
+
+// isGateHolding is true while the artifacts container keeps the pod from completing
+func isGateHolding(pod *corev1.Pod) bool {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == k8s.ArtifactsContainer {
+			return cs.State.Running != nil
+		}
+	}
+	return false
+}
+
+// gameserverExited is true once every container but the sidecar and the gate has terminated
+func gameserverExited(pod *corev1.Pod) bool {
+	exited := false
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == "sidecar" || cs.Name == k8s.ArtifactsContainer {
+			continue
+		}
+		if cs.State.Terminated == nil {
+			return false
+		}
+		exited = true
+	}
+	return exited
+}
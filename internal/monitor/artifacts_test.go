@@ -0,0 +1,111 @@
+package monitor
+
+import (
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/k8s"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	running    = corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	terminated = corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}
+)
+
+func gatedPod(phase corev1.PodPhase, sidecar, gameserver, gate corev1.ContainerState) *corev1.Pod {
+	return &corev1.Pod{
+		Status: corev1.PodStatus{
+			Phase: phase,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "sidecar", State: sidecar, Ready: sidecar.Running != nil},
+				{Name: "gameserver", State: gameserver, Ready: gameserver.Running != nil},
+				{Name: k8s.ArtifactsContainer, State: gate, Ready: gate.Running != nil},
+			},
+		},
+	}
+}
+
+func TestNextArtifactsStep(t *testing.T) {
+	collectedAt := time.Now()
+	playing := gatedPod(corev1.PodRunning, running, running, running)
+	finished := gatedPod(corev1.PodRunning, running, terminated, running)
+	sidecarGone := gatedPod(corev1.PodRunning, terminated, terminated, running)
+	completed := gatedPod(corev1.PodSucceeded, terminated, terminated, terminated)
+
+	cases := []struct {
+		name    string
+		enabled bool
+		mr      db.MatchResources
+		busy    bool
+		pods    []*corev1.Pod
+		want    artifactsStep
+		pod     *corev1.Pod
+	}{
+		{"storage off", false, db.MatchResources{}, false, []*corev1.Pod{finished}, artifactsDone, nil},
+		{"already collected", true, db.MatchResources{ArtifactsCollectedAt: &collectedAt}, false, []*corev1.Pod{completed}, artifactsDone, nil},
+		{"gameserver still runs", true, db.MatchResources{}, false, []*corev1.Pod{playing}, artifactsWait, nil},
+		{"gameserver exited", true, db.MatchResources{}, false, []*corev1.Pod{finished}, artifactsCollect, finished},
+		{"sidecar exited too", true, db.MatchResources{}, false, []*corev1.Pod{sidecarGone}, artifactsCollect, sidecarGone},
+		{"upload in flight", true, db.MatchResources{}, true, []*corev1.Pod{finished}, artifactsWait, nil},
+		{"gate timed out", true, db.MatchResources{}, false, []*corev1.Pod{completed}, artifactsMissed, nil},
+		{"no pod", true, db.MatchResources{}, false, nil, artifactsMissed, nil},
+	}
+
+	for _, c := range cases {
+		step, pod := nextArtifactsStep(c.enabled, &c.mr, c.busy, c.pods)
+		if step != c.want || pod != c.pod {
+			t.Errorf("%s: expected step %d, got %d", c.name, c.want, step)
+		}
+	}
+}
+
+// The gate must keep the match finishing, never running or done, until it opens
+func TestJobStatusWithArtifactGate(t *testing.T) {
+	job := &batchv1.Job{}
+
+	cases := []struct {
+		name string
+		pod  *corev1.Pod
+		want db.Status
+	}{
+		{"playing", gatedPod(corev1.PodRunning, running, running, running), db.StatusRunning},
+		{"gameserver exited", gatedPod(corev1.PodRunning, running, terminated, running), db.StatusFinishing},
+		{"only the gate left", gatedPod(corev1.PodRunning, terminated, terminated, running), db.StatusFinishing},
+		{"gate opened", gatedPod(corev1.PodSucceeded, terminated, terminated, terminated), db.StatusDone},
+	}
+
+	for _, c := range cases {
+		if got := getJobStatus(job, []*corev1.Pod{c.pod}); got != c.want {
+			t.Errorf("%s: expected %s, got %s", c.name, c.want, got)
+		}
+	}
+}
+
+func TestGateExpiring(t *testing.T) {
+	now := time.Now()
+	gateSince := func(started time.Time) *corev1.Pod {
+		state := corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: metav1.NewTime(started)}}
+		return gatedPod(corev1.PodRunning, running, terminated, state)
+	}
+
+	cases := []struct {
+		name string
+		pod  *corev1.Pod
+		want bool
+	}{
+		{"fresh gate", gateSince(now.Add(-time.Minute)), false},
+		{"time for one more attempt", gateSince(now.Add(-12 * time.Minute)), false},
+		{"less than an attempt left", gateSince(now.Add(-14 * time.Minute)), true},
+		{"gate gone", gatedPod(corev1.PodSucceeded, terminated, terminated, terminated), true},
+	}
+
+	for _, c := range cases {
+		if got := gateExpiring(c.pod, now, 15*time.Minute, 2*time.Minute); got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
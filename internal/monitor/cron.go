@@ -1,18 +1,22 @@
 package monitor
 
 import (
+	"context"
 	"d2c-gs-controller/internal/util"
 	"log"
 	"time"
 )
 
-func CronMatchResourceStatus() {
-	interval := util.GetEnvDuration("POD_CHECK_INTERVAL", "30s")
+// CronMatchResourceStatus is the safety net behind WatchMatchResources: a full pass over every row
+func CronMatchResourceStatus(ctx context.Context) {
+	interval := util.GetEnvDuration("POD_CHECK_INTERVAL", "5m")
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
 			err := reconcileMatches()
 			if err != nil {
@@ -22,15 +26,17 @@ func CronMatchResourceStatus() {
 	}
 }
 
-func CronServerHeartbeats() {
-	interval := time.Second * 5
+func CronServerHeartbeats(ctx context.Context) {
+	interval := util.GetEnvDuration("HEARTBEAT_CHECK_INTERVAL", "5s")
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			err := checkHeartbeats()
+			err := checkHeartbeats(ctx)
 			if err != nil {
 				log.Printf("Check heartbeats error: %v", err)
 			}
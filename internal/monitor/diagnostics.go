@@ -0,0 +1,105 @@
+package monitor
+
+import (
+	"context"
+	"d2c-gs-controller/internal/k8s"
+	"d2c-gs-controller/internal/util"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const defaultSnapshotLogLines = 50
+
+// diagnosticSnapshot is stored with every health finding, so it can be looked at after the pods are gone
+type diagnosticSnapshot struct {
+	MatchId   int64            `json:"matchId"`
+	TakenAt   time.Time        `json:"takenAt"`
+	Heartbeat *util.ServerInfo `json:"heartbeat,omitempty"`
+	Pods      []podSnapshot    `json:"pods"`
+}
+
+type podSnapshot struct {
+	Name       string              `json:"name"`
+	Node       string              `json:"node,omitempty"`
+	Phase      corev1.PodPhase     `json:"phase"`
+	Reason     string              `json:"reason,omitempty"`
+	Conditions []string            `json:"conditions"`
+	Containers []containerSnapshot `json:"containers"`
+}
+
+type containerSnapshot struct {
+	Name         string `json:"name"`
+	Ready        bool   `json:"ready"`
+	RestartCount int32  `json:"restartCount"`
+	Logs         string `json:"logs,omitempty"`
+}
+
+// takeSnapshot records the pods of the match with the tail of their container logs
+func takeSnapshot(ctx context.Context, client *kubernetes.Clientset, matchId int64, heartbeat *util.ServerInfo) *diagnosticSnapshot {
+	snapshot := &diagnosticSnapshot{
+		MatchId:   matchId,
+		TakenAt:   time.Now(),
+		Heartbeat: heartbeat,
+		Pods:      []podSnapshot{},
+	}
+
+	pods, err := client.CoreV1().Pods(k8s.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%d", matchIdLabel, matchId),
+	})
+	if err != nil {
+		log.Printf("Failed to list pods of match %d for a snapshot: %v", matchId, err)
+		return snapshot
+	}
+
+	tailLines := snapshotLogLines()
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		ps := podSnapshot{
+			Name:       pod.Name,
+			Node:       pod.Spec.NodeName,
+			Phase:      pod.Status.Phase,
+			Reason:     transitionReason([]*corev1.Pod{pod}),
+			Conditions: []string{},
+			Containers: []containerSnapshot{},
+		}
+		for _, cond := range pod.Status.Conditions {
+			ps.Conditions = append(ps.Conditions, fmt.Sprintf("%s=%s", cond.Type, cond.Status))
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			ps.Containers = append(ps.Containers, containerSnapshot{
+				Name:         cs.Name,
+				Ready:        cs.Ready,
+				RestartCount: cs.RestartCount,
+				Logs:         containerLogs(ctx, client, pod.Name, cs.Name, tailLines),
+			})
+		}
+		snapshot.Pods = append(snapshot.Pods, ps)
+	}
+	return snapshot
+}
+
+func containerLogs(ctx context.Context, client *kubernetes.Clientset, pod, container string, tailLines int64) string {
+	raw, err := client.CoreV1().Pods(k8s.Namespace).GetLogs(pod, &corev1.PodLogOptions{
+		Container: container,
+		TailLines: &tailLines,
+	}).DoRaw(ctx)
+	if err != nil {
+		return fmt.Sprintf("<logs unavailable: %v>", err)
+	}
+	return string(raw)
+}
+
+func snapshotLogLines() int64 {
+	lines, err := strconv.ParseInt(os.Getenv("HEALTH_SNAPSHOT_LOG_LINES"), 10, 64)
+	if err != nil || lines <= 0 {
+		return defaultSnapshotLogLines
+	}
+	return lines
+}
@@ -0,0 +1,89 @@
+package monitor
+
+import (
+	"d2c-gs-controller/internal/db"
+	"log"
+
+	"github.com/dota2classic/d2c-go-models/models"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type ServerAddressRequest struct {
+	MatchID int64 `json:"matchId"`
+}
+
+// ServerAddressResponse is what the website and the Discord bot show to players and spectators.
+// The addresses stay empty until the pod is scheduled.
+type ServerAddressResponse struct {
+	MatchID  int64         `json:"matchId"`
+	Region   models.Region `json:"region"`
+	Status   db.Status     `json:"status"`
+	Node     string        `json:"node,omitempty"`
+	Connect  string        `json:"connect,omitempty"`  // ip:port
+	SourceTV string        `json:"sourceTv,omitempty"` // ip:port
+}
+
+// FindServerAddress returns sql.ErrNoRows for a match the controller doesn't run
+func FindServerAddress(matchId int64) (*ServerAddressResponse, error) {
+	mr, err := db.FindMatchResources(matchId)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ServerAddressResponse{
+		MatchID: mr.MatchId,
+		Region:  mr.Region,
+		Status:  mr.Status,
+		Node:    mr.Endpoint.Node,
+	}
+	if mr.Endpoint.Known() {
+		res.Connect = mr.Endpoint.ConnectString()
+		res.SourceTV = mr.Endpoint.SourceTVAddress()
+	}
+	return res, nil
+}
+
+// recordEndpoint stores the node, host ip and ports of the match once its pod is scheduled
+func recordEndpoint(mr *db.MatchResources, pods []*corev1.Pod) {
+	node, hostIP := scheduledOn(pods)
+	if hostIP == "" || (node == mr.Endpoint.Node && hostIP == mr.Endpoint.HostIP) {
+		return
+	}
+
+	gsPort, tvPort, err := db.FindPortLease(mr.MatchId)
+	if err != nil {
+		log.Printf("Failed to find ports of match %d: %v", mr.MatchId, err)
+		return
+	}
+
+	endpoint := db.MatchEndpoint{Node: node, HostIP: hostIP, GamePort: gsPort, TVPort: tvPort}
+	changed, err := db.SetMatchEndpoint(mr.MatchId, endpoint)
+	if err != nil {
+		log.Printf("Failed to store endpoint of match %d: %v", mr.MatchId, err)
+		return
+	}
+	if changed {
+		log.Printf("Match %d is reachable at %s on node %s", mr.MatchId, endpoint.ConnectString(), node)
+	}
+	mr.Endpoint = endpoint
+}
+
+// scheduledOn returns the node and host ip of the live pod, preferring the newest one
+func scheduledOn(pods []*corev1.Pod) (string, string) {
+	var newest *corev1.Pod
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || pod.Status.HostIP == "" || pod.Spec.NodeName == "" {
+			continue
+		}
+		if pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded {
+			continue
+		}
+		if newest == nil || newest.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			newest = pod
+		}
+	}
+	if newest == nil {
+		return "", ""
+	}
+	return newest.Spec.NodeName, newest.Status.HostIP
+}
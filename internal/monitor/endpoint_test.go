@@ -0,0 +1,35 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func scheduledPod(node, hostIP string, phase corev1.PodPhase, created time.Time) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)},
+		Spec:       corev1.PodSpec{NodeName: node},
+		Status:     corev1.PodStatus{Phase: phase, HostIP: hostIP},
+	}
+}
+
+func TestScheduledOn(t *testing.T) {
+	now := time.Now()
+	failed := scheduledPod("node-a", "10.0.0.1", corev1.PodFailed, now.Add(-2*time.Minute))
+	older := scheduledPod("node-b", "10.0.0.2", corev1.PodRunning, now.Add(-time.Minute))
+	newer := scheduledPod("node-c", "10.0.0.3", corev1.PodPending, now)
+	unscheduled := unschedulablePod("0/3 nodes are available")
+
+	node, hostIP := scheduledOn([]*corev1.Pod{failed, older, newer, unscheduled})
+	if node != "node-c" || hostIP != "10.0.0.3" {
+		t.Errorf("expected the newest live pod on node-c, got %s %s", node, hostIP)
+	}
+
+	node, hostIP = scheduledOn([]*corev1.Pod{failed, unscheduled})
+	if node != "" || hostIP != "" {
+		t.Errorf("expected no endpoint without a live scheduled pod, got %s %s", node, hostIP)
+	}
+}
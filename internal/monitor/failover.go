@@ -0,0 +1,54 @@
+package monitor
+
+import (
+	"d2c-gs-controller/internal/admission"
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/rabbit"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+// prepareFailover moves a match whose pod could not be scheduled to its next fallback region; message says why.
+// It has to run before the row is deleted, the launch command is kept on it.
+func prepareFailover(mr *db.MatchResources, reason rabbit.LaunchFailureReason, message string) *models.LaunchGameServerCommand {
+	if reason != rabbit.LaunchFailureUnschedulable && reason != rabbit.LaunchFailurePortConflict {
+		return nil
+	}
+
+	cmd, err := db.FindLaunchCommand(mr.MatchId)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Failed to load launch command of match %d: %v", mr.MatchId, err)
+		}
+		return nil
+	}
+	cmd.Region = mr.Region
+
+	requested, targets, err := admission.Fallbacks(mr.MatchId, cmd.LobbyType, mr.Region)
+	if err != nil {
+		log.Printf("Failed to find fallback regions for match %d: %v", mr.MatchId, err)
+		return nil
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	if err := admission.MoveTo(cmd, requested, targets[0], message); err != nil {
+		log.Printf("Failed to record failover of match %d: %v", mr.MatchId, err)
+		return nil
+	}
+	return cmd
+}
+
+// relaunch hands the command to the consumers of its new region. The old row must be gone by then,
+// or the launch handler takes the command for a redelivery.
+func relaunch(cmd *models.LaunchGameServerCommand) bool {
+	if err := rabbit.LaunchGameServer(cmd); err != nil {
+		log.Printf("Failed to relaunch match %d in region %s: %v", cmd.MatchID, cmd.Region, err)
+		return false
+	}
+	return true
+}
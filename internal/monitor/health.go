@@ -0,0 +1,203 @@
+package monitor
+
+import (
+	"context"
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/k8s"
+	"d2c-gs-controller/internal/metrics"
+	"d2c-gs-controller/internal/util"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+/**
+Heartbeats joined with match_resources:
+- a match running in Kubernetes without a fresh heartbeat gets heartbeat_lost_at; past HEARTBEAT_GRACE it is
+  marked unhealthy and a snapshot of its pods is stored. With KILL_SILENT_MATCHES=true it is also killed.
+- a fresh heartbeat for a match without a row, for longer than HEARTBEAT_GRACE, is flagged as a rogue server
+A heartbeat that comes back clears the marks.
+*/
+
+type silenceStep int
+
+const (
+	silenceNone silenceStep = iota
+	silenceRecovered
+	silenceLost
+	silenceUnhealthy
+)
+
+// rogueSince is when a heartbeat without a row was first seen; flaggedRogues were already reported.
+// Both are only touched by the heartbeat loop of the leader.
+var (
+	rogueSince    = map[int64]time.Time{}
+	flaggedRogues = map[int64]bool{}
+)
+
+// checkMatchHealth takes the heartbeats of one check, keyed by redis key
+func checkMatchHealth(ctx context.Context, heartbeats map[string]util.ServerInfo, now time.Time) error {
+	matches, err := db.FindAllMatchResources()
+	if err != nil {
+		return err
+	}
+
+	beating := freshHeartbeats(heartbeats, now, getHeartbeatTimeout())
+	grace := getHeartbeatGrace()
+
+	rows := map[int64]bool{}
+	unhealthy := 0
+	for i := range matches {
+		mr := &matches[i]
+		rows[mr.MatchId] = true
+
+		_, ok := beating[mr.MatchId]
+		switch nextSilenceStep(mr, ok, now, grace) {
+		case silenceRecovered:
+			if mr.UnhealthyAt != nil {
+				log.Printf("Heartbeat of match %d is back after %s", mr.MatchId, now.Sub(*mr.HeartbeatLostAt).Round(time.Second))
+			}
+			if err := db.ClearHeartbeatLoss(mr.MatchId); err != nil {
+				log.Printf("Failed to clear heartbeat loss of match %d: %v", mr.MatchId, err)
+			}
+		case silenceLost:
+			if err := db.MarkHeartbeatLost(mr.MatchId); err != nil {
+				log.Printf("Failed to mark heartbeat loss of match %d: %v", mr.MatchId, err)
+			}
+		case silenceUnhealthy:
+			if markUnhealthy(ctx, mr, now) {
+				unhealthy++
+			}
+		default:
+			if mr.UnhealthyAt != nil {
+				unhealthy++
+			}
+		}
+	}
+	metrics.UnhealthyMatches.Set(float64(unhealthy))
+
+	flagRogues(ctx, beating, rows, now, grace)
+	return nil
+}
+
+// freshHeartbeats keys the heartbeats that are not stale by match id
+func freshHeartbeats(heartbeats map[string]util.ServerInfo, now time.Time, timeout time.Duration) map[int64]util.ServerInfo {
+	beating := map[int64]util.ServerInfo{}
+	for _, info := range heartbeats {
+		if info.MatchId > 0 && now.Sub(time.Unix(info.Timestamp, 0)) <= timeout {
+			beating[info.MatchId] = info
+		}
+	}
+	return beating
+}
+
+func nextSilenceStep(mr *db.MatchResources, beating bool, now time.Time, grace time.Duration) silenceStep {
+	switch {
+	case beating && mr.HeartbeatLostAt != nil:
+		return silenceRecovered
+	case beating || mr.Status != db.StatusRunning:
+		return silenceNone
+	case mr.HeartbeatLostAt == nil:
+		return silenceLost
+	case mr.UnhealthyAt == nil && now.Sub(*mr.HeartbeatLostAt) > grace:
+		return silenceUnhealthy
+	}
+	return silenceNone
+}
+
+// markUnhealthy stores a snapshot of the silent match and kills it if configured to. It returns whether the match is still around.
+func markUnhealthy(ctx context.Context, mr *db.MatchResources, now time.Time) bool {
+	changed, err := db.MarkUnhealthy(mr.MatchId)
+	if err != nil || !changed {
+		if err != nil {
+			log.Printf("Failed to mark match %d unhealthy: %v", mr.MatchId, err)
+		}
+		return false
+	}
+
+	reason := fmt.Sprintf("running without a heartbeat for %s", now.Sub(*mr.HeartbeatLostAt).Round(time.Second))
+	kill := killSilentMatches()
+	action := "reported"
+	if kill {
+		action = "killed"
+	}
+	log.Printf("Match %d is unhealthy: %s (%s)", mr.MatchId, reason, action)
+
+	client := k8s.GetClient()
+	recordDiagnostic(mr.MatchId, db.DiagnosticSilent, action, reason, takeSnapshot(ctx, client, mr.MatchId, nil))
+
+	if !kill {
+		return true
+	}
+
+	changed, err = db.TransitionStatus(mr.MatchId, mr.Status, db.StatusFailed, "", reason)
+	if err != nil {
+		log.Printf("Failed to record kill of match %d: %v", mr.MatchId, err)
+	} else if changed {
+		observeTransition(mr, db.StatusFailed)
+		emitStatusChanged(mr, db.StatusFailed, nil)
+	}
+	deleteJobAndResources(client, mr)
+	metrics.SilentMatchKills.Inc()
+	return false
+}
+
+// flagRogues reports servers that keep beating for a match the controller has no row for
+func flagRogues(ctx context.Context, beating map[int64]util.ServerInfo, rows map[int64]bool, now time.Time, grace time.Duration) {
+	for matchId := range rogueSince {
+		if _, ok := beating[matchId]; !ok || rows[matchId] {
+			delete(rogueSince, matchId)
+			delete(flaggedRogues, matchId)
+		}
+	}
+
+	for matchId, info := range beating {
+		if rows[matchId] {
+			continue
+		}
+		since, ok := rogueSince[matchId]
+		if !ok {
+			rogueSince[matchId] = now
+			continue
+		}
+		if flaggedRogues[matchId] || now.Sub(since) <= grace {
+			continue
+		}
+
+		flaggedRogues[matchId] = true
+		reason := fmt.Sprintf("%s sends heartbeats for a match without a row", info.URL)
+		log.Printf("Rogue server for match %d: %s", matchId, reason)
+		recordDiagnostic(matchId, db.DiagnosticRogue, "reported", reason, takeSnapshot(ctx, k8s.GetClient(), matchId, &info))
+	}
+
+	metrics.RogueServers.Set(float64(len(flaggedRogues)))
+}
+
+func recordDiagnostic(matchId int64, kind db.DiagnosticKind, action, reason string, snapshot *diagnosticSnapshot) {
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		log.Printf("Failed to encode snapshot of match %d: %v", matchId, err)
+		return
+	}
+
+	err = db.RecordDiagnostic(db.MatchDiagnostic{
+		MatchId:  matchId,
+		Kind:     kind,
+		Action:   action,
+		Reason:   reason,
+		Snapshot: raw,
+	})
+	if err != nil {
+		log.Printf("Failed to store snapshot of match %d: %v", matchId, err)
+	}
+}
+
+func getHeartbeatGrace() time.Duration {
+	return util.GetEnvDuration("HEARTBEAT_GRACE", "2m")
+}
+
+func killSilentMatches() bool {
+	return os.Getenv("KILL_SILENT_MATCHES") == "true"
+}
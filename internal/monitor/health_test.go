@@ -0,0 +1,51 @@
+package monitor
+
+import (
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/util"
+	"testing"
+	"time"
+)
+
+func TestNextSilenceStep(t *testing.T) {
+	now := time.Now()
+	lostLongAgo := now.Add(-5 * time.Minute)
+	lostJustNow := now.Add(-10 * time.Second)
+	grace := 2 * time.Minute
+
+	cases := []struct {
+		name    string
+		mr      db.MatchResources
+		beating bool
+		want    silenceStep
+	}{
+		{"healthy", db.MatchResources{Status: db.StatusRunning}, true, silenceNone},
+		{"not running yet", db.MatchResources{Status: db.StatusLaunching}, false, silenceNone},
+		{"just went silent", db.MatchResources{Status: db.StatusRunning}, false, silenceLost},
+		{"within grace", db.MatchResources{Status: db.StatusRunning, HeartbeatLostAt: &lostJustNow}, false, silenceNone},
+		{"past grace", db.MatchResources{Status: db.StatusRunning, HeartbeatLostAt: &lostLongAgo}, false, silenceUnhealthy},
+		{"already unhealthy", db.MatchResources{Status: db.StatusRunning, HeartbeatLostAt: &lostLongAgo, UnhealthyAt: &lostJustNow}, false, silenceNone},
+		{"heartbeat back", db.MatchResources{Status: db.StatusRunning, HeartbeatLostAt: &lostLongAgo, UnhealthyAt: &lostJustNow}, true, silenceRecovered},
+	}
+
+	for _, c := range cases {
+		if got := nextSilenceStep(&c.mr, c.beating, now, grace); got != c.want {
+			t.Errorf("%s: expected step %d, got %d", c.name, c.want, got)
+		}
+	}
+}
+
+func TestFreshHeartbeats(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	heartbeats := map[string]util.ServerInfo{
+		"server:a": {URL: "a", MatchId: 1, Timestamp: now.Unix()},
+		"server:b": {URL: "b", MatchId: 2, Timestamp: now.Add(-time.Minute).Unix()},
+		"server:c": {URL: "c", Timestamp: now.Unix()}, // idle, no match
+	}
+
+	beating := freshHeartbeats(heartbeats, now, 40*time.Second)
+
+	if len(beating) != 1 || beating[1].URL != "a" {
+		t.Errorf("expected only match 1 to be beating, got %+v", beating)
+	}
+}
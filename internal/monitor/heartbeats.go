@@ -0,0 +1,101 @@
+package monitor
+
+import (
+	"context"
+	"d2c-gs-controller/internal/metrics"
+	"d2c-gs-controller/internal/redis"
+	"d2c-gs-controller/internal/util"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+const heartbeatKeyPattern = "server:*"
+
+// heartbeatChanges is what a heartbeat check has to publish
+type heartbeatChanges struct {
+	Alive     []string // urls that started beating or came back
+	Dead      []string // urls whose heartbeat went stale or disappeared
+	StaleKeys []string // heartbeat keys to delete
+}
+
+// checkHeartbeats publishes ServerStatusEvent only when a server goes from alive to dead or back,
+// then checks the heartbeats against the matches
+func checkHeartbeats(ctx context.Context) error {
+	values, err := redis.ScanValues(ctx, heartbeatKeyPattern)
+	if err != nil {
+		return err
+	}
+	known, err := redis.HeartbeatStates(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	heartbeats := parseHeartbeats(values)
+	changes := diffHeartbeats(heartbeats, known, now, getHeartbeatTimeout())
+
+	for _, url := range changes.Alive {
+		redis.ServerStatus(url, true)
+		if err := redis.SetHeartbeatState(ctx, url, true); err != nil {
+			log.Printf("Failed to store heartbeat state of %s: %v", url, err)
+		}
+	}
+	for _, url := range changes.Dead {
+		metrics.HeartbeatTimeouts.Inc()
+		redis.ServerStatus(url, false)
+		if err := redis.ForgetHeartbeatState(ctx, url); err != nil {
+			log.Printf("Failed to forget heartbeat state of %s: %v", url, err)
+		}
+	}
+	if len(changes.StaleKeys) > 0 {
+		if err := redis.Client.Del(ctx, changes.StaleKeys...).Err(); err != nil {
+			log.Printf("Failed to delete stale heartbeats: %v", err)
+		}
+	}
+
+	return checkMatchHealth(ctx, heartbeats, now)
+}
+
+func parseHeartbeats(values map[string]string) map[string]util.ServerInfo {
+	heartbeats := make(map[string]util.ServerInfo, len(values))
+	for key, raw := range values {
+		var info util.ServerInfo
+		if err := json.Unmarshal([]byte(raw), &info); err != nil {
+			continue
+		}
+		heartbeats[key] = info
+	}
+	return heartbeats
+}
+
+// diffHeartbeats compares the heartbeats in Redis with the statuses published so far.
+// A stale heartbeat is reported dead once and its key removed; a server that vanished
+// without going stale (e.g. its key expired) is reported dead as well.
+func diffHeartbeats(heartbeats map[string]util.ServerInfo, known map[string]bool, now time.Time, timeout time.Duration) heartbeatChanges {
+	var changes heartbeatChanges
+	seen := map[string]bool{}
+
+	for key, info := range heartbeats {
+		seen[info.URL] = true
+		if now.Sub(time.Unix(info.Timestamp, 0)) > timeout {
+			changes.Dead = append(changes.Dead, info.URL)
+			changes.StaleKeys = append(changes.StaleKeys, key)
+			continue
+		}
+		if alive, ok := known[info.URL]; !ok || !alive {
+			changes.Alive = append(changes.Alive, info.URL)
+		}
+	}
+
+	for url, alive := range known {
+		if alive && !seen[url] {
+			changes.Dead = append(changes.Dead, url)
+		}
+	}
+	return changes
+}
+
+func getHeartbeatTimeout() time.Duration {
+	return util.GetEnvDuration("HEARTBEAT_TIMEOUT", "40s")
+}
@@ -0,0 +1,45 @@
+package monitor
+
+import (
+	"d2c-gs-controller/internal/util"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestDiffHeartbeats(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	fresh := now.Add(-10 * time.Second).Unix()
+	stale := now.Add(-time.Minute).Unix()
+
+	heartbeats := map[string]util.ServerInfo{
+		"server:a": {URL: "a", Timestamp: fresh}, // alive before, nothing to publish
+		"server:b": {URL: "b", Timestamp: fresh}, // new
+		"server:c": {URL: "c", Timestamp: stale}, // went stale
+	}
+	known := map[string]bool{"a": true, "c": true, "d": true} // d's key is gone
+
+	changes := diffHeartbeats(heartbeats, known, now, 40*time.Second)
+	sort.Strings(changes.Dead)
+
+	if len(changes.Alive) != 1 || changes.Alive[0] != "b" {
+		t.Errorf("alive: expected [b], got %v", changes.Alive)
+	}
+	if len(changes.Dead) != 2 || changes.Dead[0] != "c" || changes.Dead[1] != "d" {
+		t.Errorf("dead: expected [c d], got %v", changes.Dead)
+	}
+	if len(changes.StaleKeys) != 1 || changes.StaleKeys[0] != "server:c" {
+		t.Errorf("stale keys: expected [server:c], got %v", changes.StaleKeys)
+	}
+}
+
+func TestDiffHeartbeatsSteadyState(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	heartbeats := map[string]util.ServerInfo{"server:a": {URL: "a", Timestamp: now.Unix()}}
+
+	changes := diffHeartbeats(heartbeats, map[string]bool{"a": true}, now, 40*time.Second)
+
+	if len(changes.Alive)+len(changes.Dead)+len(changes.StaleKeys) != 0 {
+		t.Errorf("expected nothing to publish, got %+v", changes)
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"d2c-gs-controller/internal/db"
 	"d2c-gs-controller/internal/k8s"
+	"d2c-gs-controller/internal/metrics"
+	"d2c-gs-controller/internal/rabbit"
 	"d2c-gs-controller/internal/util"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
@@ -15,22 +18,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-func isPodFullyRunning(pod *corev1.Pod) bool {
-	if pod.Status.Phase != corev1.PodRunning {
-		return false
-	}
-
-	// All containers must be ready
-	for _, cs := range pod.Status.ContainerStatuses {
-		if !cs.Ready {
-			return false
-		}
-	}
-
-	return true
-}
-
-func getJobStatus(ctx context.Context, client *kubernetes.Clientset, job *batchv1.Job) db.Status {
+func getJobStatus(job *batchv1.Job, pods []*corev1.Pod) db.Status {
 	// 1. Check job-level completion first
 	if job.Status.Succeeded == 2 {
 		return db.StatusDone
@@ -40,18 +28,11 @@ func getJobStatus(ctx context.Context, client *kubernetes.Clientset, job *batchv
 	}
 
 	// 2. Check pods associated with this job
-	pods, err := client.CoreV1().Pods(job.Namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: fmt.Sprintf("job-name=%s", job.Name),
-	})
-	if err != nil {
-		log.Printf("Failed to list pods for job %s: %v", job.Name, err)
-		return db.StatusLaunching
-	}
-	if len(pods.Items) == 0 {
+	if len(pods) == 0 {
 		return db.StatusPending
 	}
 
-	for _, pod := range pods.Items {
+	for _, pod := range pods {
 		switch pod.Status.Phase {
 		case corev1.PodPending:
 			// Not scheduled yet
@@ -68,6 +49,10 @@ func getJobStatus(ctx context.Context, client *kubernetes.Clientset, job *batchv
 			allReady := true
 
 			for _, cs := range pod.Status.ContainerStatuses {
+				if cs.Name == k8s.ArtifactsContainer {
+					// Only holds the artifact volumes, says nothing about the match
+					continue
+				}
 				if cs.Name == "sidecar" && cs.Ready {
 					sidecarAlive = true
 				}
@@ -84,6 +69,11 @@ func getJobStatus(ctx context.Context, client *kubernetes.Clientset, job *batchv
 				return db.StatusFinishing
 			}
 
+			// Finishing: both exited, the artifact gate keeps the pod until the artifacts are copied
+			if gameserverExited(pod) && isGateHolding(pod) {
+				return db.StatusFinishing
+			}
+
 			// Running: both alive and ready
 			if sidecarAlive && mainAlive && allReady {
 				return db.StatusRunning
@@ -104,6 +94,22 @@ func getJobStatus(ctx context.Context, client *kubernetes.Clientset, job *batchv
 	return db.StatusPending
 }
 
+// listJobPods fetches the pods of a job straight from the API server
+func listJobPods(ctx context.Context, client *kubernetes.Clientset, job *batchv1.Job) ([]*corev1.Pod, error) {
+	pods, err := client.CoreV1().Pods(job.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", job.Name),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*corev1.Pod, 0, len(pods.Items))
+	for i := range pods.Items {
+		result = append(result, &pods.Items[i])
+	}
+	return result, nil
+}
+
 func KillServer(matchId int64) error {
 
 	mr, err := db.FindMatchResources(matchId)
@@ -115,29 +121,147 @@ func KillServer(matchId int64) error {
 }
 
 func deleteJobAndResources(client *kubernetes.Clientset, mr *db.MatchResources) {
-	ctx := context.Background()
+	// The ConfigMap and Secret go right away too, in case they were never adopted by the Job
+	k8s.RollbackDeployment(client, mr.MatchId, &k8s.DeployedMatch{
+		JobName:       mr.JobName,
+		ConfigMapName: mr.ConfigMapName,
+		SecretName:    mr.SecretName,
+	})
+
+	// free host ports
+	db.ReleasePorts(mr.MatchId)
+
+	// delete DB row
+	db.DeleteMatchResources(mr.MatchId)
+}
+
+func emitStatusChanged(mr *db.MatchResources, newStatus db.Status, pods []*corev1.Pod) {
+	evt := rabbit.MatchStatusChangedEvent{
+		MatchID:   mr.MatchId,
+		OldStatus: mr.Status,
+		NewStatus: newStatus,
+		Region:    mr.Region,
+		Node:      nodeOf(pods),
+	}
+
+	gsPort, tvPort, err := db.FindPortLease(mr.MatchId)
+	if err == nil {
+		evt.HostGamePort = gsPort
+		evt.HostTVPort = tvPort
+	}
+
+	rabbit.MatchStatusChanged(evt)
+}
 
-	deletePolicy := metav1.DeletePropagationBackground
+func observeTransition(mr *db.MatchResources, newStatus db.Status) {
+	metrics.StatusTransitions.WithLabelValues(string(mr.Status), string(newStatus)).Inc()
 
-	// delete Job
-	_ = client.BatchV1().Jobs(k8s.Namespace).Delete(ctx, mr.JobName, metav1.DeleteOptions{
-		PropagationPolicy: &deletePolicy,
+	region := string(mr.Region)
+	if mr.Status == db.StatusPending {
+		metrics.PendingDuration.WithLabelValues(region).Observe(time.Since(mr.CreatedAt).Seconds())
+	}
+	if newStatus == db.StatusRunning {
+		metrics.LaunchLatency.WithLabelValues(region).Observe(time.Since(mr.CreatedAt).Seconds())
+	}
+}
+
+func updatePortMetrics() {
+	metrics.PortPoolSize.Set(float64(db.PortPairCapacity()))
+
+	counts, err := db.CountPortLeases()
+	if err != nil {
+		log.Printf("Failed to count port leases: %v", err)
+		return
+	}
+
+	metrics.PortLeases.Reset()
+	for region, count := range counts {
+		metrics.PortLeases.WithLabelValues(string(region)).Set(float64(count))
+	}
+}
+
+func nodeOf(pods []*corev1.Pod) string {
+	for _, pod := range pods {
+		if pod.Spec.NodeName != "" {
+			return pod.Spec.NodeName
+		}
+	}
+	return ""
+}
+
+func emitNoFreeServer(mr *db.MatchResources, pods []*corev1.Pod) {
+	reason, message := launchFailureReason(pods)
+	waited := time.Since(mr.CreatedAt)
+
+	log.Printf("Match %d in region %s could not start after %s: %s %s", mr.MatchId, mr.Region, waited.Round(time.Second), reason, message)
+	metrics.LaunchFailures.WithLabelValues(string(mr.Region), string(reason)).Inc()
+
+	rabbit.NoFreeServer(rabbit.NoFreeServerEvent{
+		MatchID:       mr.MatchId,
+		Region:        mr.Region,
+		Reason:        reason,
+		Message:       message,
+		WaitedSeconds: int64(waited.Seconds()),
 	})
+}
 
-	// delete ConfigMap
-	_ = client.CoreV1().ConfigMaps(k8s.Namespace).Delete(ctx, mr.ConfigMapName, metav1.DeleteOptions{})
+// launchFailureReason explains why the pods never started, from container waiting reasons and pod conditions
+func launchFailureReason(pods []*corev1.Pod) (rabbit.LaunchFailureReason, string) {
+	for _, pod := range pods {
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.State.Waiting == nil {
+				continue
+			}
+			switch cs.State.Waiting.Reason {
+			case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "ErrImageNeverPull":
+				return rabbit.LaunchFailureImagePull, fmt.Sprintf("%s: %s", cs.Name, cs.State.Waiting.Message)
+			}
+		}
 
-	// delete Secret
-	_ = client.CoreV1().Secrets(k8s.Namespace).Delete(ctx, mr.SecretName, metav1.DeleteOptions{})
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type != corev1.PodScheduled || cond.Status != corev1.ConditionFalse || cond.Reason != corev1.PodReasonUnschedulable {
+				continue
+			}
+			if strings.Contains(cond.Message, "free ports") {
+				return rabbit.LaunchFailurePortConflict, cond.Message
+			}
+			return rabbit.LaunchFailureUnschedulable, cond.Message
+		}
+	}
 
-	// delete DB row
-	db.DeleteMatchResources(mr.MatchId)
+	return rabbit.LaunchFailureTimeout, ""
 }
 
-func emitNoFreeServer(mr *db.MatchResources) {
+// transitionReason explains the state a pod is in: the pod reason (e.g. Evicted), the first condition
+// that is not met, or the state of a container that isn't running
+func transitionReason(pods []*corev1.Pod) string {
+	for _, pod := range pods {
+		if pod.Status.Reason != "" {
+			return strings.TrimSpace(fmt.Sprintf("%s %s", pod.Status.Reason, pod.Status.Message))
+		}
+
+		for _, cond := range pod.Status.Conditions {
+			if cond.Status != corev1.ConditionTrue && cond.Reason != "" {
+				return strings.TrimSpace(fmt.Sprintf("%s=%s: %s %s", cond.Type, cond.Status, cond.Reason, cond.Message))
+			}
+		}
 
+		for _, cs := range pod.Status.ContainerStatuses {
+			if state := cs.State.Terminated; state != nil {
+				return fmt.Sprintf("%s terminated: %s (exit code %d)", cs.Name, state.Reason, state.ExitCode)
+			}
+			if state := cs.State.Waiting; state != nil && state.Reason != "" {
+				return fmt.Sprintf("%s waiting: %s", cs.Name, state.Reason)
+			}
+		}
+	}
+	return ""
 }
 
 func getExpirationTimeout() time.Duration {
 	return util.GetEnvDuration("GAMESERVER_EXPIRATION_TIMEOUT", "2m")
 }
+
+func getPortLeaseGrace() time.Duration {
+	return util.GetEnvDuration("PORT_LEASE_GRACE", "5m")
+}
@@ -0,0 +1,95 @@
+package monitor
+
+import (
+	"d2c-gs-controller/internal/rabbit"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func unschedulablePod(message string) *corev1.Pod {
+	return &corev1.Pod{
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			Conditions: []corev1.PodCondition{{
+				Type:    corev1.PodScheduled,
+				Status:  corev1.ConditionFalse,
+				Reason:  corev1.PodReasonUnschedulable,
+				Message: message,
+			}},
+		},
+	}
+}
+
+func TestLaunchFailureReason(t *testing.T) {
+	imagePull := &corev1.Pod{
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: "gameserver",
+				State: corev1.ContainerState{
+					Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "not found"},
+				},
+			}},
+		},
+	}
+
+	cases := []struct {
+		name string
+		pods []*corev1.Pod
+		want rabbit.LaunchFailureReason
+	}{
+		{"no pods", nil, rabbit.LaunchFailureTimeout},
+		{"image pull", []*corev1.Pod{imagePull}, rabbit.LaunchFailureImagePull},
+		{"port conflict", []*corev1.Pod{unschedulablePod("0/3 nodes are available: 3 node(s) didn't have free ports for the requested pod ports.")}, rabbit.LaunchFailurePortConflict},
+		{"unschedulable", []*corev1.Pod{unschedulablePod("0/3 nodes are available: 3 Insufficient cpu.")}, rabbit.LaunchFailureUnschedulable},
+	}
+
+	for _, c := range cases {
+		got, _ := launchFailureReason(c.pods)
+		if got != c.want {
+			t.Errorf("%s: expected %s, got %s", c.name, c.want, got)
+		}
+	}
+}
+
+func TestTransitionReason(t *testing.T) {
+	evicted := &corev1.Pod{
+		Status: corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Evicted", Message: "low on memory"},
+	}
+	crashed := &corev1.Pod{
+		Status: corev1.PodStatus{
+			Phase: corev1.PodFailed,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: "gameserver",
+				State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 139},
+				},
+			}},
+		},
+	}
+	running := &corev1.Pod{
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+
+	cases := []struct {
+		name string
+		pods []*corev1.Pod
+		want string
+	}{
+		{"no pods", nil, ""},
+		{"evicted", []*corev1.Pod{evicted}, "Evicted low on memory"},
+		{"unschedulable", []*corev1.Pod{unschedulablePod("0/3 nodes are available")}, "PodScheduled=False: Unschedulable 0/3 nodes are available"},
+		{"crashed", []*corev1.Pod{crashed}, "gameserver terminated: Error (exit code 139)"},
+		{"running", []*corev1.Pod{running}, ""},
+	}
+
+	for _, c := range cases {
+		if got := transitionReason(c.pods); got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.name, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package monitor
+
+import (
+	"context"
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/k8s"
+	"d2c-gs-controller/internal/util"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	gameserverJobSelector = "app-type=gameserver"
+	matchIdLabel          = "ru.dotaclassic/matchId"
+)
+
+type matchWatcher struct {
+	client *kubernetes.Clientset
+	jobs   batchlisters.JobLister
+	pods   corelisters.PodLister
+	queue  *workqueue.Typed[int64]
+}
+
+// WatchMatchResources reacts to gameserver Job and Pod changes as Kubernetes reports them.
+// Every change is queued by match id, so one match is never reconciled twice at the same time.
+// It returns when ctx is done.
+func WatchMatchResources(ctx context.Context) {
+	client := k8s.GetClient()
+	resync := util.GetEnvDuration("INFORMER_RESYNC_INTERVAL", "30s")
+
+	jobFactory := informers.NewSharedInformerFactoryWithOptions(client, resync,
+		informers.WithNamespace(k8s.Namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = gameserverJobSelector
+		}),
+	)
+	podFactory := informers.NewSharedInformerFactoryWithOptions(client, resync,
+		informers.WithNamespace(k8s.Namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = matchIdLabel
+		}),
+	)
+
+	jobInformer := jobFactory.Batch().V1().Jobs()
+	podInformer := podFactory.Core().V1().Pods()
+
+	w := &matchWatcher{
+		client: client,
+		jobs:   jobInformer.Lister(),
+		pods:   podInformer.Lister(),
+		queue:  workqueue.NewTyped[int64](),
+	}
+	defer w.queue.ShutDown()
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    w.enqueue,
+		UpdateFunc: func(_, obj interface{}) { w.enqueue(obj) },
+		DeleteFunc: w.enqueue,
+	}
+	if _, err := jobInformer.Informer().AddEventHandler(handler); err != nil {
+		log.Printf("Failed to watch jobs: %v", err)
+		return
+	}
+	if _, err := podInformer.Informer().AddEventHandler(handler); err != nil {
+		log.Printf("Failed to watch pods: %v", err)
+		return
+	}
+
+	stopCh := ctx.Done()
+	go func() {
+		<-stopCh
+		w.queue.ShutDown()
+	}()
+
+	jobFactory.Start(stopCh)
+	podFactory.Start(stopCh)
+
+	if !cache.WaitForCacheSync(stopCh, jobInformer.Informer().HasSynced, podInformer.Informer().HasSynced) {
+		log.Printf("Failed to sync gameserver informers")
+		return
+	}
+	log.Println("Gameserver informers synced")
+
+	for w.processNext() {
+	}
+	jobFactory.Shutdown()
+	podFactory.Shutdown()
+}
+
+func (w *matchWatcher) enqueue(obj interface{}) {
+	if matchId, ok := matchIdOf(obj); ok {
+		w.queue.Add(matchId)
+	}
+}
+
+func (w *matchWatcher) processNext() bool {
+	matchId, shutdown := w.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer w.queue.Done(matchId)
+
+	w.sync(matchId)
+	return true
+}
+
+func (w *matchWatcher) sync(matchId int64) {
+	mr, err := db.FindMatchResources(matchId)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Failed to find match resources for %d: %v", matchId, err)
+		}
+		// No row yet or already cleaned up
+		return
+	}
+
+	if provisioningInProgress(w.client, mr) {
+		return
+	}
+
+	job, err := w.jobs.Jobs(k8s.Namespace).Get(mr.JobName)
+	if k8serrors.IsNotFound(err) {
+		// The pod informer may be ahead of the job informer, so ask the API server before cleaning up
+		job, err = w.client.BatchV1().Jobs(k8s.Namespace).Get(context.Background(), mr.JobName, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			deleteJobAndResources(w.client, mr)
+			return
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to get job %s: %v", mr.JobName, err)
+		return
+	}
+
+	pods, err := w.pods.Pods(k8s.Namespace).List(labels.SelectorFromSet(labels.Set{"job-name": job.Name}))
+	if err != nil {
+		log.Printf("Failed to list pods for job %s: %v", job.Name, err)
+		return
+	}
+
+	reconcileMatch(w.client, mr, job, pods)
+}
+
+func matchIdOf(obj interface{}) (int64, bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	var objLabels map[string]string
+	switch o := obj.(type) {
+	case *batchv1.Job:
+		// Claimed warm jobs only get the label on the job itself, their pod template is immutable
+		objLabels = o.Spec.Template.Labels
+		if _, ok := o.Labels[matchIdLabel]; ok {
+			objLabels = o.Labels
+		}
+	case *corev1.Pod:
+		objLabels = o.Labels
+	default:
+		return 0, false
+	}
+
+	matchId, err := strconv.ParseInt(objLabels[matchIdLabel], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return matchId, true
+}
@@ -0,0 +1,160 @@
+package monitor
+
+import (
+	"context"
+	"d2c-gs-controller/internal/admission"
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/k8s"
+	"d2c-gs-controller/internal/metrics"
+	"d2c-gs-controller/internal/rabbit"
+	"d2c-gs-controller/internal/util"
+	"log"
+	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+// CronLaunchQueue releases queued launches once their region has room. It runs on the leader only.
+func CronLaunchQueue(ctx context.Context) {
+	if admission.CurrentMode() != admission.ModeQueue {
+		return
+	}
+
+	interval := util.GetEnvDuration("ADMISSION_QUEUE_INTERVAL", "5s")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := releaseQueuedLaunches(ctx)
+			if err != nil {
+				log.Printf("Launch queue error: %v", err)
+			}
+		}
+	}
+}
+
+func releaseQueuedLaunches(ctx context.Context) error {
+	launches, err := db.FindQueuedLaunches()
+	if err != nil {
+		return err
+	}
+
+	timeout := util.GetEnvDuration("ADMISSION_QUEUE_TIMEOUT", "2m")
+
+	byRegion := map[models.Region][]db.QueuedLaunch{}
+	for _, l := range launches {
+		byRegion[l.Region] = append(byRegion[l.Region], l)
+	}
+
+	metrics.LaunchQueueLength.Reset()
+	for region, queue := range byRegion {
+		waiting := releaseRegion(ctx, region, queue, timeout)
+		metrics.LaunchQueueLength.WithLabelValues(string(region)).Set(float64(waiting))
+	}
+	return nil
+}
+
+// releaseRegion goes through the queue in priority order and stops at the first launch that doesn't fit,
+// so smaller launches don't starve bigger ones. It returns how many launches are still waiting.
+func releaseRegion(ctx context.Context, region models.Region, queue []db.QueuedLaunch, timeout time.Duration) int {
+	var waiting []db.QueuedLaunch
+	released := 0
+	for _, l := range queue {
+		switch {
+		case l.ReleasedAt != nil && time.Since(*l.ReleasedAt) > timeout:
+			// Released but never consumed, e.g. the command was dropped on the way
+			log.Printf("Forgetting queued match %d, released %s ago", l.MatchId, time.Since(*l.ReleasedAt).Round(time.Second))
+			if err := db.DeleteQueuedLaunch(l.MatchId); err != nil {
+				log.Printf("Failed to remove queued match %d: %v", l.MatchId, err)
+			}
+		case l.ReleasedAt != nil:
+			released++
+		case time.Since(l.EnqueuedAt) > timeout:
+			expireQueuedLaunch(&l)
+		default:
+			waiting = append(waiting, l)
+		}
+	}
+
+	// One estimate per request size for the whole pass; every release takes its room off all of them
+	capacities := map[string]*k8s.RegionCapacity{}
+	for i, l := range waiting {
+		settings, err := db.ResolveSettings(l.Command.LobbyType, region)
+		if err != nil {
+			log.Printf("Failed to resolve settings for queued match %d: %v", l.MatchId, err)
+			return len(waiting) - i
+		}
+		request, err := k8s.MatchRequests(settings)
+		if err != nil {
+			log.Printf("Failed to build requests for queued match %d: %v", l.MatchId, err)
+			return len(waiting) - i
+		}
+
+		key := request.Cpu().String() + "/" + request.Memory().String()
+		capacity, ok := capacities[key]
+		if !ok {
+			capacity, err = k8s.FindRegionCapacity(region, request)
+			if err != nil {
+				log.Printf("Failed to estimate capacity of region %s: %v", region, err)
+				return len(waiting) - i
+			}
+			// Launches released earlier are not on the cluster yet, they still need their room
+			for j := 0; j < released+i; j++ {
+				capacity.Reserve()
+			}
+			capacities[key] = capacity
+		}
+		if !capacity.Fits() {
+			return len(waiting) - i
+		}
+
+		if err := releaseQueuedLaunch(&l); err != nil {
+			log.Printf("Failed to release queued match %d: %v", l.MatchId, err)
+			return len(waiting) - i
+		}
+		for _, c := range capacities {
+			c.Reserve()
+		}
+	}
+	return 0
+}
+
+// releaseQueuedLaunch puts the command back on the exchange; admission lets it through because it is marked released
+func releaseQueuedLaunch(l *db.QueuedLaunch) error {
+	if err := db.SetQueuedLaunchReleased(l.MatchId, true); err != nil {
+		return err
+	}
+	if err := rabbit.LaunchGameServer(&l.Command); err != nil {
+		if err := db.SetQueuedLaunchReleased(l.MatchId, false); err != nil {
+			log.Printf("Failed to put match %d back in the queue: %v", l.MatchId, err)
+		}
+		return err
+	}
+
+	log.Printf("Released match %d in region %s after %s in the queue", l.MatchId, l.Region, time.Since(l.EnqueuedAt).Round(time.Second))
+	return nil
+}
+
+func expireQueuedLaunch(l *db.QueuedLaunch) {
+	waited := time.Since(l.EnqueuedAt)
+	log.Printf("Match %d waited %s for capacity in region %s, giving up", l.MatchId, waited.Round(time.Second), l.Region)
+
+	if err := db.DeleteQueuedLaunch(l.MatchId); err != nil {
+		log.Printf("Failed to remove queued match %d: %v", l.MatchId, err)
+		return
+	}
+
+	metrics.AdmissionDecisions.WithLabelValues(string(l.Region), "expired").Inc()
+	metrics.LaunchFailures.WithLabelValues(string(l.Region), string(rabbit.LaunchFailureNoCapacity)).Inc()
+	rabbit.NoFreeServer(rabbit.NoFreeServerEvent{
+		MatchID:       l.MatchId,
+		Region:        l.Region,
+		Reason:        rabbit.LaunchFailureNoCapacity,
+		Message:       "no capacity freed up while the launch was queued",
+		WaitedSeconds: int64(waited.Seconds()),
+	})
+}
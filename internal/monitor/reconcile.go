@@ -4,16 +4,17 @@ import (
 	"context"
 	"d2c-gs-controller/internal/db"
 	"d2c-gs-controller/internal/k8s"
-	"d2c-gs-controller/internal/redis"
-	"d2c-gs-controller/internal/util"
-	"encoding/json"
+	"fmt"
 	"log"
 	"time"
 
 	//"time"
 
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 )
 
 func reconcileMatches() error {
@@ -26,6 +27,10 @@ func reconcileMatches() error {
 	client := k8s.GetClient()
 
 	for _, mr := range matchResources {
+		if provisioningInProgress(client, &mr) {
+			continue
+		}
+
 		// Query job from Kubernetes
 		job, err := client.BatchV1().Jobs(k8s.Namespace).Get(context.Background(), mr.JobName, metav1.GetOptions{})
 		if err != nil {
@@ -38,73 +43,98 @@ func reconcileMatches() error {
 			continue
 		}
 
-		jobStatus := getJobStatus(context.Background(), client, job)
-
-		err = db.UpdateStatus(mr.MatchId, jobStatus)
+		pods, err := listJobPods(context.Background(), client, job)
 		if err != nil {
-			log.Printf("failed to update status for job %s: %v", mr.JobName, err)
+			log.Printf("Failed to list pods for job %s: %v", job.Name, err)
+			continue
 		}
 
-		switch jobStatus {
-		case db.StatusPending, db.StatusLaunching:
-			log.Printf("Job %s is launching/pending", mr.JobName)
-			if mr.CreatedAt.Add(getExpirationTimeout()).Before(time.Now()) {
-				log.Printf("Cancelling stale job: its pending too long %s", mr.JobName)
-				deleteJobAndResources(client, &mr)
-				emitNoFreeServer(&mr)
-			}
-		case db.StatusDone:
-			log.Printf("Job %s done, cleaning up resources", mr.JobName)
-			deleteJobAndResources(client, &mr)
-		case db.StatusFailed:
-			log.Printf("Job %s failed! cleaning up resources", mr.JobName)
-			deleteJobAndResources(client, &mr)
-		case db.StatusRunning:
-			log.Printf("Job %s is running", mr.JobName)
-		}
+		reconcileMatch(client, &mr, job, pods)
+	}
 
-		// Check Job status
+	reclaimed, err := db.ReclaimStalePortLeases(getPortLeaseGrace())
+	if err != nil {
+		log.Printf("Failed to reclaim stale port leases: %v", err)
+	} else if reclaimed > 0 {
+		log.Printf("Reclaimed %d stale port leases", reclaimed)
 	}
 
+	updatePortMetrics()
+
 	return nil
 }
 
-func checkHeartbeats() error {
-	ctx := context.Background()
-	client := redis.Client
-
-	keys, err := client.Keys(ctx, "server:*").Result()
-	if err != nil {
-		return err
+// provisioningInProgress is true while a launch is still creating the match objects.
+// A row stuck in provisioning past the expiration timeout belongs to a crashed launch and is cleaned up.
+func provisioningInProgress(client *kubernetes.Clientset, mr *db.MatchResources) bool {
+	if mr.Status != db.StatusProvisioning {
+		return false
+	}
+	if mr.CreatedAt.Add(getExpirationTimeout()).Before(time.Now()) {
+		log.Printf("Match %d stuck in provisioning, cleaning up", mr.MatchId)
+		deleteJobAndResources(client, mr)
 	}
+	return true
+}
 
-	now := time.Now()
-	timeout := 40 * time.Second
+// reconcileMatch stores a status change and cleans up matches that are over or stuck
+func reconcileMatch(client *kubernetes.Clientset, mr *db.MatchResources, job *batchv1.Job, pods []*corev1.Pod) {
+	jobStatus := getJobStatus(job, pods)
 
-	for _, key := range keys {
-		raw, err := client.Get(ctx, key).Result()
+	if jobStatus != mr.Status {
+		log.Printf("Match %d status %s -> %s", mr.MatchId, mr.Status, jobStatus)
+		changed, err := db.TransitionStatus(mr.MatchId, mr.Status, jobStatus, nodeOf(pods), transitionReason(pods))
 		if err != nil {
-			continue
+			log.Printf("failed to update status for job %s: %v", mr.JobName, err)
+		} else if changed {
+			observeTransition(mr, jobStatus)
+			emitStatusChanged(mr, jobStatus, pods)
+			mr.Status = jobStatus
 		}
+	}
 
-		var info util.ServerInfo
-		if err := json.Unmarshal([]byte(raw), &info); err != nil {
-			continue
+	recordEndpoint(mr, pods)
+
+	switch jobStatus {
+	case db.StatusPending, db.StatusLaunching:
+		log.Printf("Job %s is launching/pending", mr.JobName)
+		if mr.CreatedAt.Add(getExpirationTimeout()).Before(time.Now()) {
+			log.Printf("Cancelling stale job: its pending too long %s", mr.JobName)
+			// Keep a trace in the history, the row itself is about to go
+			reason, message := launchFailureReason(pods)
+			if message != "" {
+				message = fmt.Sprintf("%s: %s", reason, message)
+			} else {
+				message = string(reason)
+			}
+			_, err := db.TransitionStatus(mr.MatchId, mr.Status, db.StatusFailed, nodeOf(pods), message)
+			if err != nil {
+				log.Printf("failed to record expiry of match %d: %v", mr.MatchId, err)
+			}
+			failover := prepareFailover(mr, reason, message)
+			deleteJobAndResources(client, mr)
+			if failover == nil || !relaunch(failover) {
+				emitNoFreeServer(mr, pods)
+			}
 		}
-
-		ts := time.Unix(info.Timestamp, 0)
-
-		if now.Sub(ts) > timeout {
-			// Server considered dead
-			redis.ServerStatus(info.URL, false)
-
-			// Optional: delete the key
-			client.Del(ctx, key)
-		} else {
-			// Server alive
-			redis.ServerStatus(info.URL, true)
+	case db.StatusFinishing:
+		// Copy the artifacts out once the gameserver exited, the gate holds the pod until then
+		artifactsCollected(mr, pods)
+	case db.StatusDone:
+		if !artifactsCollected(mr, pods) {
+			log.Printf("Job %s done, waiting for its artifacts", mr.JobName)
+			return
 		}
+		log.Printf("Job %s done, cleaning up resources", mr.JobName)
+		deleteJobAndResources(client, mr)
+	case db.StatusFailed:
+		if !artifactsCollected(mr, pods) {
+			log.Printf("Job %s failed, waiting for its artifacts", mr.JobName)
+			return
+		}
+		log.Printf("Job %s failed! cleaning up resources", mr.JobName)
+		deleteJobAndResources(client, mr)
+	case db.StatusRunning:
+		log.Printf("Job %s is running", mr.JobName)
 	}
-
-	return nil
 }
@@ -0,0 +1,338 @@
+package monitor
+
+import (
+	"context"
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/k8s"
+	"d2c-gs-controller/internal/metrics"
+	"d2c-gs-controller/internal/util"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+/**
+Reconcile walks match_resources rows; the orphan sweeper walks the namespace instead.
+Every Job, ConfigMap and Secret labelled app-type=gameserver or named gameserver-* must have a row.
+Objects younger than ORPHAN_SWEEP_GRACE are skipped, their launch may still be in flight.
+- report:  log and count orphans, touch nothing
+- enforce: adopt jobs that still run, delete everything else and write it to orphan_sweeps
+*/
+
+type sweepMode string
+
+const (
+	sweepOff     sweepMode = "off"
+	sweepReport  sweepMode = "report"
+	sweepEnforce sweepMode = "enforce"
+)
+
+const (
+	regionLabel      = "ru.dotaclassic/region"
+	gameserverPrefix = "gameserver-"
+)
+
+func getSweepMode() sweepMode {
+	switch mode := sweepMode(os.Getenv("ORPHAN_SWEEP_MODE")); mode {
+	case sweepOff, sweepEnforce:
+		return mode
+	case "", sweepReport:
+		return sweepReport
+	default:
+		log.Printf("Unknown ORPHAN_SWEEP_MODE %q, using %s", mode, sweepReport)
+		return sweepReport
+	}
+}
+
+// CronOrphanSweep periodically looks for gameserver objects that lost their match_resources row
+func CronOrphanSweep(ctx context.Context) {
+	mode := getSweepMode()
+	if mode == sweepOff {
+		log.Println("Orphan sweeper disabled")
+		return
+	}
+
+	interval := util.GetEnvDuration("ORPHAN_SWEEP_INTERVAL", "10m")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := sweepOrphans(ctx, mode)
+			if err != nil {
+				log.Printf("Orphan sweep error: %v", err)
+			}
+		}
+	}
+}
+
+type orphanSweeper struct {
+	ctx    context.Context
+	client *kubernetes.Clientset
+	mode   sweepMode
+	grace  time.Duration
+
+	// known matches have a row; kept ones have a job we could not delete, so their config stays
+	known map[int64]bool
+	kept  map[int64]bool
+	found map[string]int
+}
+
+func sweepOrphans(ctx context.Context, mode sweepMode) error {
+	rows, err := db.FindAllMatchResources()
+	if err != nil {
+		return err
+	}
+
+	s := &orphanSweeper{
+		ctx:    ctx,
+		client: k8s.GetClient(),
+		mode:   mode,
+		grace:  util.GetEnvDuration("ORPHAN_SWEEP_GRACE", "10m"),
+		known:  map[int64]bool{},
+		kept:   map[int64]bool{},
+		found:  map[string]int{"Job": 0, "ConfigMap": 0, "Secret": 0},
+	}
+	for _, mr := range rows {
+		s.known[mr.MatchId] = true
+	}
+
+	jobs, err := s.client.BatchV1().Jobs(k8s.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	configMaps, err := s.client.CoreV1().ConfigMaps(k8s.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	secrets, err := s.client.CoreV1().Secrets(k8s.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	// Jobs first: adopting one makes its ConfigMap and Secret known
+	for i := range jobs.Items {
+		s.sweepJob(&jobs.Items[i])
+	}
+	for i := range configMaps.Items {
+		s.sweepConfig("ConfigMap", &configMaps.Items[i].ObjectMeta, func(name string) error {
+			return s.client.CoreV1().ConfigMaps(k8s.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		})
+	}
+	for i := range secrets.Items {
+		s.sweepConfig("Secret", &secrets.Items[i].ObjectMeta, func(name string) error {
+			return s.client.CoreV1().Secrets(k8s.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		})
+	}
+
+	for kind, count := range s.found {
+		metrics.OrphanedObjects.WithLabelValues(kind).Set(float64(count))
+	}
+	log.Printf("[Sweeper] Found %d orphaned jobs, %d configmaps, %d secrets (mode %s)",
+		s.found["Job"], s.found["ConfigMap"], s.found["Secret"], s.mode)
+	return nil
+}
+
+// orphanOf returns the match id of an object that has no row and is old enough to act on
+func (s *orphanSweeper) orphanOf(kind string, meta *metav1.ObjectMeta, podLabels map[string]string) (int64, bool) {
+	if !isGameserverObject(meta) {
+		return 0, false
+	}
+	// Unclaimed warm pool servers are looked after by the pool itself
+	if meta.Labels[k8s.WarmServerLabel] != "" && meta.Labels[matchIdLabel] == "" {
+		return 0, false
+	}
+
+	matchId, ok := orphanMatchId(meta, podLabels)
+	if !ok {
+		log.Printf("[Sweeper] %s %s carries no match id, leaving it alone", kind, meta.Name)
+		return 0, false
+	}
+	if s.known[matchId] || time.Since(meta.CreationTimestamp.Time) < s.grace {
+		return 0, false
+	}
+	return matchId, true
+}
+
+func (s *orphanSweeper) sweepJob(job *batchv1.Job) {
+	matchId, orphan := s.orphanOf("Job", &job.ObjectMeta, job.Spec.Template.Labels)
+	if !orphan {
+		return
+	}
+
+	s.found["Job"]++
+	finished := k8s.IsJobFinished(job)
+	if s.mode == sweepReport {
+		log.Printf("[Sweeper] Orphaned Job %s of match %d (finished: %v)", job.Name, matchId, finished)
+		return
+	}
+
+	if !finished {
+		err := adoptJob(job, matchId)
+		if err == nil {
+			s.known[matchId] = true
+			s.record("Job", job.Name, matchId, db.OrphanAdopted, "job still running")
+			return
+		}
+		log.Printf("[Sweeper] Could not adopt Job %s: %v", job.Name, err)
+	}
+
+	deletePolicy := metav1.DeletePropagationBackground
+	err := s.client.BatchV1().Jobs(k8s.Namespace).Delete(s.ctx, job.Name, metav1.DeleteOptions{
+		PropagationPolicy: &deletePolicy,
+	})
+	if err != nil {
+		k8s.LogDeleteError("Job", job.Name, err)
+		s.kept[matchId] = true
+		return
+	}
+
+	reason := "job finished"
+	if !finished {
+		reason = "job running but not adoptable"
+	}
+	s.record("Job", job.Name, matchId, db.OrphanDeleted, reason)
+}
+
+// sweepConfig handles ConfigMaps and Secrets. Owned ones are left to garbage collection of their Job.
+func (s *orphanSweeper) sweepConfig(kind string, meta *metav1.ObjectMeta, deleteFn func(name string) error) {
+	if len(meta.OwnerReferences) > 0 {
+		return
+	}
+
+	matchId, orphan := s.orphanOf(kind, meta, nil)
+	if !orphan || s.kept[matchId] {
+		return
+	}
+
+	s.found[kind]++
+	if s.mode == sweepReport {
+		log.Printf("[Sweeper] Orphaned %s %s of match %d", kind, meta.Name, matchId)
+		return
+	}
+
+	if err := deleteFn(meta.Name); err != nil {
+		k8s.LogDeleteError(kind, meta.Name, err)
+		return
+	}
+	s.record(kind, meta.Name, matchId, db.OrphanDeleted, "no match_resources row")
+}
+
+func (s *orphanSweeper) record(kind, name string, matchId int64, action db.OrphanAction, reason string) {
+	log.Printf("[Sweeper] %s %s %s of match %d: %s", action, kind, name, matchId, reason)
+	metrics.OrphanSweeps.WithLabelValues(kind, string(action)).Inc()
+
+	err := db.RecordOrphanSweep(db.OrphanSweep{
+		MatchId: matchId,
+		Kind:    kind,
+		Name:    name,
+		Action:  action,
+		Reason:  reason,
+	})
+	if err != nil {
+		log.Printf("[Sweeper] Failed to write audit log: %v", err)
+	}
+}
+
+// adoptJob gives a running job its row and port lease back, reconcile takes it from there
+func adoptJob(job *batchv1.Job, matchId int64) error {
+	region := jobRegion(job)
+	if region == "" {
+		return fmt.Errorf("job has no region")
+	}
+
+	gsPort, tvPort, ok := jobHostPorts(job)
+	if !ok {
+		return fmt.Errorf("job has no host ports")
+	}
+
+	claimed, err := db.ClaimPortLease(matchId, region, gsPort, tvPort)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return fmt.Errorf("ports %d/%d are leased to another match", gsPort, tvPort)
+	}
+
+	err = db.InsertMatchResources(db.MatchResources{
+		MatchId:       matchId,
+		JobName:       job.Name,
+		SecretName:    fmt.Sprintf("gameserver-secrets-%d", matchId),
+		ConfigMapName: fmt.Sprintf("gameserver-config-%d", matchId),
+		Region:        region,
+		Status:        db.StatusPending,
+	})
+	if err != nil {
+		db.ReleasePorts(matchId)
+		return err
+	}
+	return nil
+}
+
+func isGameserverObject(meta *metav1.ObjectMeta) bool {
+	return meta.Labels["app-type"] == "gameserver" || strings.HasPrefix(meta.Name, gameserverPrefix)
+}
+
+// orphanMatchId reads the match id label, falling back to the numeric suffix of the name for older objects
+func orphanMatchId(meta *metav1.ObjectMeta, podLabels map[string]string) (int64, bool) {
+	for _, labels := range []map[string]string{meta.Labels, podLabels} {
+		if raw, ok := labels[matchIdLabel]; ok {
+			if matchId, err := strconv.ParseInt(raw, 10, 64); err == nil {
+				return matchId, true
+			}
+		}
+	}
+
+	if !strings.HasPrefix(meta.Name, gameserverPrefix) {
+		return 0, false
+	}
+	matchId, err := strconv.ParseInt(meta.Name[strings.LastIndex(meta.Name, "-")+1:], 10, 64)
+	return matchId, err == nil
+}
+
+// jobRegion reads the region label, or the required node affinity of jobs created before it existed
+func jobRegion(job *batchv1.Job) models.Region {
+	if region := job.Labels[regionLabel]; region != "" {
+		return models.Region(region)
+	}
+
+	affinity := job.Spec.Template.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return ""
+	}
+	for _, term := range affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		for _, expr := range term.MatchExpressions {
+			if expr.Key == regionLabel && expr.Operator == corev1.NodeSelectorOpIn && len(expr.Values) == 1 {
+				return models.Region(expr.Values[0])
+			}
+		}
+	}
+	return ""
+}
+
+func jobHostPorts(job *batchv1.Job) (int, int, bool) {
+	var gsPort, tvPort int
+	for _, container := range job.Spec.Template.Spec.Containers {
+		for _, port := range container.Ports {
+			switch port.Name {
+			case "tcp-27015":
+				gsPort = int(port.HostPort)
+			case "tcp-27020":
+				tvPort = int(port.HostPort)
+			}
+		}
+	}
+	return gsPort, tvPort, gsPort != 0 && tvPort != 0
+}
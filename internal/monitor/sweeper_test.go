@@ -0,0 +1,57 @@
+package monitor
+
+import (
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOrphanMatchId(t *testing.T) {
+	cases := []struct {
+		name      string
+		meta      metav1.ObjectMeta
+		podLabels map[string]string
+		want      int64
+		ok        bool
+	}{
+		{"label", metav1.ObjectMeta{Name: "whatever", Labels: map[string]string{matchIdLabel: "42"}}, nil, 42, true},
+		{"pod template label", metav1.ObjectMeta{Name: "gameserver-cpu-affinity-job-7"}, map[string]string{matchIdLabel: "43"}, 43, true},
+		{"legacy name", metav1.ObjectMeta{Name: "gameserver-secrets-44"}, nil, 44, true},
+		{"foreign name", metav1.ObjectMeta{Name: "shared-gameserver-secrets"}, nil, 0, false},
+		{"no number", metav1.ObjectMeta{Name: "gameserver-config-abc"}, nil, 0, false},
+	}
+
+	for _, c := range cases {
+		got, ok := orphanMatchId(&c.meta, c.podLabels)
+		if got != c.want || ok != c.ok {
+			t.Errorf("%s: orphanMatchId = (%d, %v), want (%d, %v)", c.name, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestJobRegionFromAffinity(t *testing.T) {
+	job := &batchv1.Job{}
+	job.Spec.Template.Spec.Affinity = &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+					MatchExpressions: []corev1.NodeSelectorRequirement{
+						{Key: "ru.dotaclassic/nodeType", Operator: corev1.NodeSelectorOpIn, Values: []string{"gameserver"}},
+						{Key: regionLabel, Operator: corev1.NodeSelectorOpIn, Values: []string{"eu_czech"}},
+					},
+				}},
+			},
+		},
+	}
+
+	if region := jobRegion(job); region != "eu_czech" {
+		t.Errorf("jobRegion = %q, want eu_czech", region)
+	}
+
+	job.Labels = map[string]string{regionLabel: "ru_moscow"}
+	if region := jobRegion(job); region != "ru_moscow" {
+		t.Errorf("jobRegion = %q, want the label ru_moscow", region)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/redis/go-redis/v9"
 )
@@ -14,15 +15,23 @@ import (
 type HealthServer struct {
 	redis  *redis.Client
 	rabbit *amqp.Connection
+	mux    *http.ServeMux
+	server *http.Server
 }
 
 func NewHealthServer(redis *redis.Client, rabbit *amqp.Connection) *HealthServer {
 	return &HealthServer{
 		redis:  redis,
 		rabbit: rabbit,
+		mux:    http.NewServeMux(),
 	}
 }
 
+// Handle mounts an extra handler on the server, e.g. the admin API. Call it before Start.
+func (h *HealthServer) Handle(pattern string, handler http.Handler) {
+	h.mux.Handle(pattern, handler)
+}
+
 func (h *HealthServer) Liveness(w http.ResponseWriter, r *http.Request) {
 	// Liveness just means: the process is alive.
 	w.WriteHeader(http.StatusOK)
@@ -61,8 +70,16 @@ func (h *HealthServer) Readiness(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *HealthServer) Start(port int) error {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/healthz", h.Readiness)
-	mux.HandleFunc("/readyz", h.Readiness)
-	return http.ListenAndServe(fmt.Sprintf("0.0.0.0:%d", port), mux)
+	h.mux.HandleFunc("/healthz", h.Liveness)
+	h.mux.HandleFunc("/readyz", h.Readiness)
+	h.mux.Handle("/metrics", promhttp.Handler())
+	h.server = &http.Server{Addr: fmt.Sprintf("0.0.0.0:%d", port), Handler: h.mux}
+	return h.server.ListenAndServe()
+}
+
+func (h *HealthServer) Shutdown(ctx context.Context) error {
+	if h.server == nil {
+		return nil
+	}
+	return h.server.Shutdown(ctx)
 }
@@ -1 +1,106 @@
 package rabbit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	publishAttempts = 3
+	confirmTimeout  = 5 * time.Second
+)
+
+var ErrNotConnected = errors.New("rabbitmq not connected")
+
+// Publish sends an event to the exchange and waits for the broker to confirm it
+func (r *Rabbit) Publish(routingKey string, event any) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("can't serialize payload: %w", err)
+	}
+
+	for attempt := 1; attempt <= publishAttempts; attempt++ {
+		err = r.publishConfirmed(routingKey, body)
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("RabbitMQ publish to %s failed (attempt %d/%d): %v", routingKey, attempt, publishAttempts, err)
+		time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+	}
+
+	return fmt.Errorf("rabbitmq publish failed after %d attempts: %w", publishAttempts, err)
+}
+
+func (r *Rabbit) publishConfirmed(routingKey string, body []byte) error {
+	r.pubMu.Lock()
+	defer r.pubMu.Unlock()
+
+	ch, err := r.publishChannel()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), confirmTimeout)
+	defer cancel()
+
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(ctx, r.exchange, routingKey, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		Body:         body,
+	})
+	if err != nil {
+		r.resetPublishChannel()
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		r.resetPublishChannel()
+		return err
+	}
+	if !acked {
+		return errors.New("message nacked by broker")
+	}
+	return nil
+}
+
+// publishChannel returns the confirm-mode channel, reopening it after a connection loss.
+// It never dials: reconnecting the shared connection is left to the consumers,
+// so a broker outage can't block callers like the reconcile loop.
+func (r *Rabbit) publishChannel() (*amqp.Channel, error) {
+	if r.pubCh != nil && !r.pubCh.IsClosed() {
+		return r.pubCh, nil
+	}
+
+	if r.Conn == nil || r.Conn.IsClosed() {
+		return nil, ErrNotConnected
+	}
+
+	ch, err := r.Conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, err
+	}
+
+	r.pubCh = ch
+	return ch, nil
+}
+
+func (r *Rabbit) resetPublishChannel() {
+	if r.pubCh != nil {
+		_ = r.pubCh.Close()
+		r.pubCh = nil
+	}
+}
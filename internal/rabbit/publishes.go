@@ -0,0 +1,82 @@
+package rabbit
+
+import (
+	"d2c-gs-controller/internal/db"
+	"log"
+	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+type MatchStatusChangedEvent struct {
+	MatchID      int64         `json:"matchId"`
+	OldStatus    db.Status     `json:"oldStatus"`
+	NewStatus    db.Status     `json:"newStatus"`
+	Region       models.Region `json:"region,omitempty"`
+	Node         string        `json:"node,omitempty"`
+	HostGamePort int           `json:"hostGamePort,omitempty"`
+	HostTVPort   int           `json:"hostTvPort,omitempty"`
+	Placement
+	Timestamp int64 `json:"timestamp"` // Unix timestamp in seconds
+}
+
+// Placement tells where a failed over match was requested and what it costs its players
+type Placement struct {
+	RequestedRegion  models.Region `json:"requestedRegion,omitempty"`
+	LatencyPenaltyMs int           `json:"latencyPenaltyMs,omitempty"`
+}
+
+// placementOf is empty unless the match was moved to the region it is reported in
+func placementOf(matchId int64, region models.Region) Placement {
+	failover, err := db.FindLastFailover(matchId)
+	if err != nil || failover.ToRegion != region {
+		return Placement{}
+	}
+	return Placement{RequestedRegion: failover.RequestedRegion, LatencyPenaltyMs: failover.LatencyPenaltyMs}
+}
+
+// MatchStatusChanged queues the event and returns right away, see status_events.go
+func MatchStatusChanged(evt MatchStatusChangedEvent) {
+	if evt.Timestamp == 0 {
+		evt.Timestamp = time.Now().Unix()
+	}
+	Instance.queueStatusEvent(evt)
+}
+
+type LaunchFailureReason string
+
+const (
+	LaunchFailureUnschedulable LaunchFailureReason = "unschedulable"
+	LaunchFailureImagePull     LaunchFailureReason = "image_pull_error"
+	LaunchFailurePortConflict  LaunchFailureReason = "port_conflict"
+	LaunchFailureTimeout       LaunchFailureReason = "timeout"
+	LaunchFailureNoCapacity    LaunchFailureReason = "no_capacity"
+)
+
+type NoFreeServerEvent struct {
+	MatchID       int64               `json:"matchId"`
+	Region        models.Region       `json:"region"`
+	Reason        LaunchFailureReason `json:"reason"`
+	Message       string              `json:"message,omitempty"`
+	WaitedSeconds int64               `json:"waitedSeconds"`
+	Placement
+	Timestamp int64 `json:"timestamp"` // Unix timestamp in seconds
+}
+
+func NoFreeServer(evt NoFreeServerEvent) {
+	if evt.Timestamp == 0 {
+		evt.Timestamp = time.Now().Unix()
+	}
+	if evt.RequestedRegion == "" {
+		evt.Placement = placementOf(evt.MatchID, evt.Region)
+	}
+
+	if err := Instance.Publish("NoFreeServerEvent", &evt); err != nil {
+		log.Printf("There was an issue publishing NoFreeServerEvent for match %d: %v", evt.MatchID, err)
+	}
+}
+
+// LaunchGameServer puts a launch command on the exchange, the same way the matchmaker does
+func LaunchGameServer(evt *models.LaunchGameServerCommand) error {
+	return Instance.Publish(launchRoutingKey(evt.Region), evt)
+}
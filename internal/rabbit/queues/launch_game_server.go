@@ -2,30 +2,147 @@ package queues
 
 import (
 	"context"
+	"d2c-gs-controller/internal/admission"
 	"d2c-gs-controller/internal/db"
 	"d2c-gs-controller/internal/k8s"
+	"d2c-gs-controller/internal/metrics"
+	"d2c-gs-controller/internal/warmpool"
+	"database/sql"
+	"errors"
 	"log"
 
 	"github.com/dota2classic/d2c-go-models/models"
 )
 
-func HandleLaunchGameServerCommand(event *models.LaunchGameServerCommand) error {
+/**
+Launch saga:
+1. write the match_resources row as provisioning, so a crash at any later step leaves a trace
+2. create ConfigMap, Secret and Job, each tolerating leftovers from an earlier attempt
+3. mark the row pending and hand it over to reconcile
+Any failure in 2 rolls back the objects, the port lease and the row.
+With the warm pool enabled, 1 and 2 become a claim of a warm server; an empty pool means a cold start.
+A cold start of a new match first passes admission, which may reject or queue it when the region is full.
+*/
+
+// HandleLaunchGameServerCommand deploys the match. ctx is only cancelled when shutdown runs out of time,
+// in which case everything created so far is rolled back.
+func HandleLaunchGameServerCommand(ctx context.Context, event *models.LaunchGameServerCommand) error {
 	log.Printf("Launching game server for matchId %d", event.MatchID)
-	mr, err := k8s.DeployMatchResources(context.Background(), k8s.GetClient(), event)
+
+	existing, err := db.FindMatchResources(event.MatchID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if existing != nil && existing.Status != db.StatusProvisioning {
+		log.Printf("Match %d is already %s, nothing to do for redelivered command", event.MatchID, existing.Status)
+		return nil
+	}
+
+	// The command still names the requested region; a resumed launch stays where it failed over to
+	if existing != nil && existing.Region != event.Region {
+		log.Printf("Match %d was moved to region %s, resuming there", event.MatchID, existing.Region)
+		event.Region = existing.Region
+	}
+
+	// An interrupted warm claim can't be resumed, the sidecar may never have got the match
+	if existing != nil && warmpool.IsWarmJob(existing.JobName) {
+		log.Printf("Match %d was interrupted while claiming warm job %s, starting over", event.MatchID, existing.JobName)
+		rollback(event.MatchID, deployedOf(existing))
+		existing = nil
+	}
+
+	if existing == nil && warmpool.Enabled() {
+		deployed, err := warmpool.Claim(ctx, event)
+		if err == nil {
+			return markPending(event, deployed)
+		}
+		if !errors.Is(err, warmpool.ErrNoWarmServer) {
+			log.Printf("Warm launch of match %d failed, starting cold: %v", event.MatchID, err)
+		}
+	}
+
+	if existing == nil {
+		admitted, err := admission.Admit(event)
+		if errors.Is(err, admission.ErrRegionFull) {
+			log.Printf("Rejecting match %d: %v", event.MatchID, err)
+			metrics.Launches.WithLabelValues(string(event.Region), "failure").Inc()
+			return err
+		}
+		if err != nil || !admitted {
+			return err
+		}
+	}
+
+	plan, err := k8s.PlanMatchResources(event)
 	if err != nil {
-		log.Printf("Failed to deploy match: %v", err)
+		log.Printf("Failed to plan match: %v", err)
+		if existing != nil {
+			rollback(event.MatchID, deployedOf(existing))
+		}
+		launchFailed(event.Region)
 		return err
 	}
-	log.Printf("Match %d successfully deployed", event.MatchID)
+
+	names := plan.Names()
 	err = db.InsertMatchResources(db.MatchResources{
 		MatchId:       event.MatchID,
-		JobName:       mr.JobName,
-		SecretName:    mr.SecretName,
-		ConfigMapName: mr.ConfigMapName,
+		JobName:       names.JobName,
+		SecretName:    names.SecretName,
+		ConfigMapName: names.ConfigMapName,
+		Region:        event.Region,
+		Status:        db.StatusProvisioning,
 	})
 	if err != nil {
 		log.Printf("Failed to insert match: %v", err)
+		db.ReleasePorts(event.MatchID)
+		launchFailed(event.Region)
 		return err
 	}
+
+	err = k8s.ApplyMatchPlan(ctx, k8s.GetClient(), plan)
+	if err != nil {
+		log.Printf("Failed to deploy match: %v", err)
+		rollback(event.MatchID, names)
+		launchFailed(event.Region)
+		return err
+	}
+
+	return markPending(event, names)
+}
+
+// markPending hands the deployed match over to reconcile.
+// Objects exist now; if this fails the redelivered command resumes from the provisioning row.
+func markPending(event *models.LaunchGameServerCommand, deployed *k8s.DeployedMatch) error {
+	if err := db.SaveLaunchCommand(event); err != nil {
+		log.Printf("Failed to keep launch command of match %d, it won't fail over: %v", event.MatchID, err)
+	}
+
+	_, err := db.TransitionStatus(event.MatchID, db.StatusProvisioning, db.StatusPending, "", "")
+	if err != nil {
+		log.Printf("Failed to mark match %d pending: %v", event.MatchID, err)
+		return err
+	}
+
+	log.Printf("Match %d successfully deployed as job %s", event.MatchID, deployed.JobName)
+	metrics.Launches.WithLabelValues(string(event.Region), "success").Inc()
 	return nil
 }
+
+func deployedOf(mr *db.MatchResources) *k8s.DeployedMatch {
+	return &k8s.DeployedMatch{
+		ConfigMapName: mr.ConfigMapName,
+		SecretName:    mr.SecretName,
+		JobName:       mr.JobName,
+	}
+}
+
+func rollback(matchId int64, deployed *k8s.DeployedMatch) {
+	k8s.RollbackDeployment(k8s.GetClient(), matchId, deployed)
+	db.ReleasePorts(matchId)
+	db.DeleteMatchResources(matchId)
+}
+
+func launchFailed(region models.Region) {
+	metrics.Launches.WithLabelValues(string(region), "failure").Inc()
+	metrics.LaunchFailures.WithLabelValues(string(region), "deploy_error").Inc()
+}
@@ -1,11 +1,14 @@
 package rabbit
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"sync"
 	"time"
 
+	"github.com/dota2classic/d2c-go-models/models"
 	"github.com/dota2classic/d2c-go-models/util"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -14,16 +17,46 @@ type Rabbit struct {
 	amqpURL  string
 	exchange string
 	Conn     *amqp.Connection
+
+	pubMu sync.Mutex
+	pubCh *amqp.Channel
+
+	consumersMu sync.Mutex
+	consumers   map[models.Region]context.CancelFunc
+	consumersWg sync.WaitGroup
+	watcherDone chan struct{} // closed once watchRegions can't start consumers anymore
+
+	statusEvents chan MatchStatusChangedEvent
+	statusDone   chan struct{}
+
+	// ctx stops consumers; workCtx is handed to in-flight handlers and only cancelled when draining times out
+	ctx        context.Context
+	workCtx    context.Context
+	cancelWork context.CancelFunc
 }
 
-func NewRabbit(amqpURL string) *Rabbit {
-	return &Rabbit{amqpURL: amqpURL, exchange: "app.events"}
+func NewRabbit(ctx context.Context, amqpURL string) *Rabbit {
+	workCtx, cancelWork := context.WithCancel(context.Background())
+	return &Rabbit{
+		amqpURL:      amqpURL,
+		exchange:     "app.events",
+		consumers:    map[models.Region]context.CancelFunc{},
+		watcherDone:  make(chan struct{}),
+		statusEvents: make(chan MatchStatusChangedEvent, statusEventBuffer),
+		statusDone:   make(chan struct{}),
+		ctx:          ctx,
+		workCtx:      workCtx,
+		cancelWork:   cancelWork,
+	}
 }
 
 var Instance *Rabbit
 
 const (
 	Exchange = "app.events"
+
+	// rollbackGrace is the part of the shutdown deadline cancelled handlers get to roll back
+	rollbackGrace = 10 * time.Second
 )
 
 // Connect establishes the connection with auto-reconnect
@@ -60,7 +93,7 @@ func (r *Rabbit) getChannel() (*amqp.Channel, error) {
 	}
 }
 
-func InitRabbit() {
+func InitRabbit(ctx context.Context) {
 	host := os.Getenv("RABBITMQ_HOST")
 	port := util.GetEnvInt("RABBITMQ_PORT", 5672)
 
@@ -71,7 +104,7 @@ func InitRabbit() {
 
 	amqpURL := fmt.Sprintf("amqp://%s:%s@%s:%d/", username, password, host, port)
 
-	Instance = NewRabbit(amqpURL)
+	Instance = NewRabbit(ctx, amqpURL)
 
 	ch, err := Instance.getChannel()
 	if err != nil {
@@ -95,6 +128,46 @@ func InitRabbit() {
 	}
 
 	Instance.initConsumers()
+	go Instance.publishStatusEvents()
 
 	log.Println("RabbitMQ consumer initialized")
 }
+
+// Shutdown waits for consumers to finish their in-flight message, then closes the connection, all before deadline.
+// Consumers stop taking deliveries as soon as the context given to InitRabbit is cancelled.
+// Handlers still running rollbackGrace before the deadline get their context cancelled and roll back.
+func (r *Rabbit) Shutdown(deadline time.Time) {
+	drained := make(chan struct{})
+	go func() {
+		// The region watcher may still be starting a consumer; join it before waiting for them
+		<-r.watcherDone
+		r.consumersWg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		log.Println("RabbitMQ consumers drained")
+	case <-time.After(time.Until(deadline.Add(-rollbackGrace))):
+		log.Printf("In-flight messages not done, cancelling them")
+		r.cancelWork()
+		select {
+		case <-drained:
+		case <-time.After(time.Until(deadline)):
+			log.Printf("RabbitMQ consumers did not stop, closing connection anyway")
+		}
+	}
+	r.cancelWork()
+	r.flushStatusEvents(deadline)
+
+	r.pubMu.Lock()
+	r.resetPublishChannel()
+	r.pubMu.Unlock()
+
+	if r.Conn != nil && !r.Conn.IsClosed() {
+		if err := r.Conn.Close(); err != nil {
+			log.Printf("Failed to close RabbitMQ connection: %v", err)
+		}
+	}
+	log.Println("RabbitMQ connection closed")
+}
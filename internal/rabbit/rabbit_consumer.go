@@ -1,7 +1,10 @@
 package rabbit
 
 import (
+	"context"
+	"d2c-gs-controller/internal/admission"
 	"d2c-gs-controller/internal/k8s"
+	"d2c-gs-controller/internal/metrics"
 	"d2c-gs-controller/internal/rabbit/queues"
 	"encoding/json"
 	"errors"
@@ -13,31 +16,59 @@ import (
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-var regions = []models.Region{
-	models.REGION_RU_MOSCOW,
-	models.REGION_RU_NOVOSIBIRSK,
-	models.REGION_EU_CZECH,
+func launchQueueName(region models.Region) string {
+	return fmt.Sprintf("d2c-gs-controller.LaunchGameServerCommand.%s", region)
+}
+
+func launchRoutingKey(region models.Region) string {
+	return fmt.Sprintf("LaunchGameServerCommand.%s", region)
+}
+
+// isRetryable is false for errors that another attempt can't fix.
+// A rejected launch is not retried either: the match would start long after the players gave up.
+func isRetryable(err error) bool {
+	return !isParkable(err) && !errors.Is(err, admission.ErrRegionFull)
+}
+
+// isParkable keeps messages that should be replayed once configuration is fixed
+func isParkable(err error) bool {
+	return errors.Is(err, k8s.ErrNoImageConfigured) || errors.Is(err, k8s.ErrInvalidResources)
 }
 
 func (r *Rabbit) initConsumers() {
-	// Start multiple consumers
-	for _, region := range regions {
-		key := fmt.Sprintf("LaunchGameServerCommand.%s", region)
-
-		r.startConsuming(fmt.Sprintf("d2c-gs-controller.LaunchGameServerCommand.%s", region), Exchange, key, 10, func(msg *amqp.Delivery) error {
-			var event models.LaunchGameServerCommand
-			if err := json.Unmarshal(msg.Body, &event); err != nil {
-				return err
-			}
-			return queues.HandleLaunchGameServerCommand(&event)
-		})
+	if err := r.syncRegions(); err != nil {
+		log.Fatalf("Failed to load regions: %v", err)
 	}
+	go r.watchRegions()
 }
 
-// startConsuming starts a consumer for a given queue and handler
-func (r *Rabbit) startConsuming(queue, exchange, key string, maxRetries int, handler func(msg *amqp.Delivery) error) {
+func (r *Rabbit) startRegionConsumer(ctx context.Context, region models.Region) {
+	r.startConsuming(ctx, launchQueueName(region), Exchange, launchRoutingKey(region), 10, func(workCtx context.Context, msg *amqp.Delivery) error {
+		var event models.LaunchGameServerCommand
+		if err := json.Unmarshal(msg.Body, &event); err != nil {
+			return err
+		}
+		err := queues.HandleLaunchGameServerCommand(workCtx, &event)
+		if errors.Is(err, admission.ErrRegionFull) {
+			metrics.LaunchFailures.WithLabelValues(string(event.Region), string(LaunchFailureNoCapacity)).Inc()
+			NoFreeServer(NoFreeServerEvent{
+				MatchID: event.MatchID,
+				Region:  event.Region,
+				Reason:  LaunchFailureNoCapacity,
+				Message: err.Error(),
+			})
+		}
+		return err
+	})
+}
+
+// startConsuming starts a consumer for a given queue and handler. It stops once ctx is cancelled,
+// after the message being handled at that moment is acked or rejected.
+func (r *Rabbit) startConsuming(ctx context.Context, queue, exchange, key string, maxRetries int, handler func(workCtx context.Context, msg *amqp.Delivery) error) {
+	r.consumersWg.Add(1)
 	go func() {
-		for {
+		defer r.consumersWg.Done()
+		for ctx.Err() == nil {
 			ch, err := r.getChannel()
 			if err != nil {
 				time.Sleep(2 * time.Second)
@@ -52,6 +83,14 @@ func (r *Rabbit) startConsuming(queue, exchange, key string, maxRetries int, han
 				continue
 			}
 
+			err = declareRetryTopology(ch, queue)
+			if err != nil {
+				log.Printf("Retry queues declare failed: %v", err)
+				ch.Close()
+				time.Sleep(2 * time.Second)
+				continue
+			}
+
 			err = ch.QueueBind(queue, key, exchange, false, nil)
 			if err != nil {
 				log.Printf("Queue bind failed: %v", err)
@@ -60,43 +99,53 @@ func (r *Rabbit) startConsuming(queue, exchange, key string, maxRetries int, han
 				continue
 			}
 
-			msgs, err := ch.Consume(queue, "", false, false, false, false, nil)
+			// Retries and parking are published on this channel and must be confirmed before the ack
+			err = ch.Confirm(false)
 			if err != nil {
-				log.Printf("Consume failed: %v", err)
+				log.Printf("Confirm mode failed: %v", err)
 				ch.Close()
 				time.Sleep(2 * time.Second)
 				continue
 			}
 
-			for m := range msgs {
-				if err := handler(&m); err != nil {
-					// calculate retry count
-					retryCount := 0
-					if deaths, ok := m.Headers["x-death"].([]interface{}); ok && len(deaths) > 0 {
-						if death, ok := deaths[0].(amqp.Table); ok {
-							if count, ok := death["count"].(int64); ok {
-								retryCount = int(count)
-							}
-						}
-					}
+			msgs, err := ch.Consume(queue, queue, false, false, false, false, nil)
+			if err != nil {
+				log.Printf("Consume failed: %v", err)
+				ch.Close()
+				time.Sleep(2 * time.Second)
+				continue
+			}
 
-					// if its any error other than "job already exists", we requeue
-					shouldRequeue := retryCount < maxRetries && !errors.Is(err, k8s.ErrJobAlreadyExists)
+			// Cancelling the consumer closes msgs once the in-flight message is handled
+			done := make(chan struct{})
+			go func() {
+				select {
+				case <-ctx.Done():
+					_ = ch.Cancel(queue, false)
+				case <-done:
+				}
+			}()
 
-					if shouldRequeue {
-						log.Printf("Message failed, retrying (%d/%d): %v", retryCount+1, maxRetries, err)
-					} else {
-						log.Printf("Message failed, max retries reached or not retryable: %v", err)
+			for m := range msgs {
+				if err := handler(r.workCtx, &m); err != nil {
+					if r.workCtx.Err() != nil {
+						// Interrupted by shutdown, not a real failure: hand it to another replica
+						m.Nack(false, true)
+						continue
 					}
-
-					m.Nack(false, shouldRequeue)
+					handleFailure(ch, queue, &m, maxRetries, err)
 				} else {
 					m.Ack(false)
 				}
 			}
 
+			close(done)
 			ch.Close()
+			if ctx.Err() != nil {
+				break
+			}
 			time.Sleep(2 * time.Second)
 		}
+		log.Printf("Stopped consuming %s", queue)
 	}()
 }
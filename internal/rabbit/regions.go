@@ -0,0 +1,111 @@
+package rabbit
+
+import (
+	"context"
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/util"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+// instanceRegions reads CONTROLLER_REGIONS, the comma separated subset of regions this instance serves.
+// Empty means every enabled region.
+func instanceRegions() map[models.Region]bool {
+	raw := os.Getenv("CONTROLLER_REGIONS")
+	if raw == "" {
+		return nil
+	}
+
+	subset := map[models.Region]bool{}
+	for _, region := range strings.Split(raw, ",") {
+		region = strings.TrimSpace(region)
+		if region != "" {
+			subset[models.Region(region)] = true
+		}
+	}
+	return subset
+}
+
+func filterRegions(enabled []models.Region, subset map[models.Region]bool) []models.Region {
+	if subset == nil {
+		return enabled
+	}
+
+	var regions []models.Region
+	for _, region := range enabled {
+		if subset[region] {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// syncRegions starts consumers for newly enabled regions and stops the ones that were removed
+func (r *Rabbit) syncRegions() error {
+	enabled, err := db.FindEnabledRegions()
+	if err != nil {
+		return err
+	}
+	regions := filterRegions(enabled, instanceRegions())
+
+	r.consumersMu.Lock()
+	defer r.consumersMu.Unlock()
+
+	wanted := map[models.Region]bool{}
+	for _, region := range regions {
+		wanted[region] = true
+		if _, running := r.consumers[region]; running {
+			continue
+		}
+
+		log.Printf("Starting consumer for region %s", region)
+		ctx, cancel := context.WithCancel(r.ctx)
+		r.consumers[region] = cancel
+		r.startRegionConsumer(ctx, region)
+	}
+
+	for region, cancel := range r.consumers {
+		if !wanted[region] {
+			log.Printf("Stopping consumer for region %s", region)
+			cancel()
+			delete(r.consumers, region)
+		}
+	}
+
+	return nil
+}
+
+// Regions returns the regions this instance is consuming launch commands for
+func (r *Rabbit) Regions() []models.Region {
+	r.consumersMu.Lock()
+	defer r.consumersMu.Unlock()
+
+	regions := make([]models.Region, 0, len(r.consumers))
+	for region := range r.consumers {
+		regions = append(regions, region)
+	}
+	return regions
+}
+
+func (r *Rabbit) watchRegions() {
+	defer close(r.watcherDone)
+
+	interval := util.GetEnvDuration("REGION_REFRESH_INTERVAL", "30s")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.syncRegions(); err != nil {
+				log.Printf("Region refresh error: %v", err)
+			}
+		}
+	}
+}
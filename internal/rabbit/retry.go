@@ -0,0 +1,231 @@
+package rabbit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+/**
+Failed deliveries never go back to the head of their queue:
+- retryable failures are copied to the retry queue of their delay step, <queue>.retry.5s, .retry.10s
+  and so on; every step has its own queue TTL, so a long delay never holds back a short one, and
+  dead-letters expired messages back into <queue>
+- exhausted or misconfigured ones are copied to <queue>.parking and stay there until replayed
+*/
+
+const (
+	retryHeader  = "x-retry-count"
+	reasonHeader = "x-last-error"
+
+	baseRetryDelay = 5 * time.Second
+	maxRetryDelay  = 2 * time.Minute
+)
+
+// retryQueueName is the queue of one delay step. The single <queue>.retry of older versions still
+// dead-letters what it holds back into <queue> and can be deleted once it is empty.
+func retryQueueName(queue string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%ds", queue, int(delay.Seconds()))
+}
+
+func parkingQueueName(queue string) string {
+	return queue + ".parking"
+}
+
+func declareRetryTopology(ch *amqp.Channel, queue string) error {
+	for _, delay := range retryDelays() {
+		_, err := ch.QueueDeclare(retryQueueName(queue, delay), true, false, false, false, amqp.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := ch.QueueDeclare(parkingQueueName(queue), true, false, false, false, nil)
+	return err
+}
+
+// retryCount reads our own counter first; x-death is only a fallback for messages
+// that were dead-lettered before the counter existed
+func retryCount(m *amqp.Delivery) int {
+	if count, ok := headerInt(m.Headers[retryHeader]); ok {
+		return count
+	}
+
+	if deaths, ok := m.Headers["x-death"].([]interface{}); ok && len(deaths) > 0 {
+		if death, ok := deaths[0].(amqp.Table); ok {
+			if count, ok := headerInt(death["count"]); ok {
+				return count
+			}
+		}
+	}
+	return 0
+}
+
+func headerInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+// retryDelay doubles with every attempt up to maxRetryDelay
+func retryDelay(attempt int) time.Duration {
+	delay := baseRetryDelay
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// retryDelays lists every delay step, one retry queue each
+func retryDelays() []time.Duration {
+	var delays []time.Duration
+	for attempt := 1; ; attempt++ {
+		delay := retryDelay(attempt)
+		delays = append(delays, delay)
+		if delay >= maxRetryDelay {
+			return delays
+		}
+	}
+}
+
+// republish copies the delivery into another queue through the default exchange and waits for the broker confirm
+func republish(ch *amqp.Channel, queue string, m *amqp.Delivery, retries int, cause error) error {
+	headers := amqp.Table{}
+	for k, v := range m.Headers {
+		if k != "x-death" {
+			headers[k] = v
+		}
+	}
+	headers[retryHeader] = int64(retries)
+	if cause != nil {
+		headers[reasonHeader] = cause.Error()
+	}
+
+	msg := amqp.Publishing{
+		Headers:      headers,
+		ContentType:  m.ContentType,
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		Body:         m.Body,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), confirmTimeout)
+	defer cancel()
+
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(ctx, "", queue, false, false, msg)
+	if err != nil {
+		return err
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return errors.New("message nacked by broker")
+	}
+	return nil
+}
+
+// handleFailure decides between dropping, retrying later and parking a failed delivery
+func handleFailure(ch *amqp.Channel, queue string, m *amqp.Delivery, maxRetries int, cause error) {
+	if !isRetryable(cause) {
+		if isParkable(cause) {
+			park(ch, queue, m, cause)
+			return
+		}
+		log.Printf("Message failed, not retryable: %v", cause)
+		m.Nack(false, false)
+		return
+	}
+
+	retries := retryCount(m)
+	if retries >= maxRetries {
+		log.Printf("Message failed, max retries reached (%d): %v", maxRetries, cause)
+		park(ch, queue, m, cause)
+		return
+	}
+
+	delay := retryDelay(retries + 1)
+	log.Printf("Message failed, retrying (%d/%d) in %s: %v", retries+1, maxRetries, delay, cause)
+
+	if err := republish(ch, retryQueueName(queue, delay), m, retries+1, cause); err != nil {
+		log.Printf("Failed to schedule retry, requeueing: %v", err)
+		m.Nack(false, true)
+		return
+	}
+	m.Ack(false)
+}
+
+func park(ch *amqp.Channel, queue string, m *amqp.Delivery, cause error) {
+	log.Printf("Parking message in %s: %v", parkingQueueName(queue), cause)
+
+	if err := republish(ch, parkingQueueName(queue), m, retryCount(m), cause); err != nil {
+		log.Printf("Failed to park message, requeueing: %v", err)
+		m.Nack(false, true)
+		return
+	}
+	m.Ack(false)
+}
+
+type ReplayParkedRequest struct {
+	Region models.Region `json:"region"`
+	Limit  int           `json:"limit"` // 0 replays everything
+}
+
+type ReplayParkedResponse struct {
+	Replayed int `json:"replayed"`
+}
+
+// ReplayParked moves up to limit parked launch commands of the region back into its queue with a fresh retry budget
+func (r *Rabbit) ReplayParked(region models.Region, limit int) (int, error) {
+	queue := launchQueueName(region)
+
+	ch, err := r.getChannel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	if err := ch.Confirm(false); err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for limit <= 0 || replayed < limit {
+		m, ok, err := ch.Get(parkingQueueName(queue), false)
+		if err != nil {
+			return replayed, err
+		}
+		if !ok {
+			break
+		}
+
+		if err := republish(ch, queue, &m, 0, nil); err != nil {
+			m.Nack(false, true)
+			return replayed, fmt.Errorf("replay of parked message failed: %w", err)
+		}
+		m.Ack(false)
+		replayed++
+	}
+
+	log.Printf("Replayed %d parked launch commands for region %s", replayed, region)
+	return replayed, nil
+}
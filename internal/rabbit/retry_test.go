@@ -0,0 +1,56 @@
+package rabbit
+
+import (
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestRetryDelayBackoff(t *testing.T) {
+	expected := []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second, 80 * time.Second, maxRetryDelay, maxRetryDelay}
+	for i, want := range expected {
+		if got := retryDelay(i + 1); got != want {
+			t.Errorf("attempt %d: expected %s, got %s", i+1, want, got)
+		}
+	}
+}
+
+func TestRetryQueues(t *testing.T) {
+	delays := retryDelays()
+	if len(delays) != 6 || delays[0] != baseRetryDelay || delays[5] != maxRetryDelay {
+		t.Fatalf("expected 6 delay steps from %s to %s, got %v", baseRetryDelay, maxRetryDelay, delays)
+	}
+
+	queue := "d2c-gs-controller.LaunchGameServerCommand.ru_moscow"
+	expected := map[int]string{
+		1:  queue + ".retry.5s",
+		2:  queue + ".retry.10s",
+		6:  queue + ".retry.120s",
+		10: queue + ".retry.120s",
+	}
+	for attempt, want := range expected {
+		if got := retryQueueName(queue, retryDelay(attempt)); got != want {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, want, got)
+		}
+	}
+}
+
+func TestRetryCount(t *testing.T) {
+	fresh := &amqp.Delivery{}
+	if got := retryCount(fresh); got != 0 {
+		t.Errorf("fresh delivery: expected 0, got %d", got)
+	}
+
+	counted := &amqp.Delivery{Headers: amqp.Table{retryHeader: int32(3)}}
+	if got := retryCount(counted); got != 3 {
+		t.Errorf("x-retry-count: expected 3, got %d", got)
+	}
+
+	deadLettered := &amqp.Delivery{Headers: amqp.Table{
+		"x-death": []interface{}{amqp.Table{"count": int64(2)}},
+	}}
+	if got := retryCount(deadLettered); got != 2 {
+		t.Errorf("x-death: expected 2, got %d", got)
+	}
+}
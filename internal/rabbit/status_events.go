@@ -0,0 +1,65 @@
+package rabbit
+
+import (
+	"log"
+	"time"
+)
+
+/**
+MatchStatusChangedEvent is published off the caller's goroutine: reconcile runs in a single informer worker,
+and a confirmed publish can take several confirm timeouts while the broker is unreachable.
+- MatchStatusChanged only stamps the event and puts it in a buffer
+- one publisher drains the buffer in order, so a match's transitions reach consumers in the order they happened
+- a full buffer makes callers wait; Shutdown flushes what is left before the connection closes
+*/
+
+const statusEventBuffer = 1024
+
+func (r *Rabbit) queueStatusEvent(evt MatchStatusChangedEvent) {
+	select {
+	case r.statusEvents <- evt:
+	default:
+		log.Printf("MatchStatusChangedEvent buffer is full, waiting to queue match %d", evt.MatchID)
+		r.statusEvents <- evt
+	}
+}
+
+// publishStatusEvents runs until the context given to InitRabbit is cancelled, then publishes what is still queued
+func (r *Rabbit) publishStatusEvents() {
+	defer close(r.statusDone)
+
+	for {
+		select {
+		case evt := <-r.statusEvents:
+			r.publishStatusEvent(&evt)
+		case <-r.ctx.Done():
+			for {
+				select {
+				case evt := <-r.statusEvents:
+					r.publishStatusEvent(&evt)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *Rabbit) publishStatusEvent(evt *MatchStatusChangedEvent) {
+	if evt.Region != "" && evt.RequestedRegion == "" {
+		evt.Placement = placementOf(evt.MatchID, evt.Region)
+	}
+
+	if err := r.Publish("MatchStatusChangedEvent", evt); err != nil {
+		log.Printf("There was an issue publishing MatchStatusChangedEvent for match %d: %v", evt.MatchID, err)
+	}
+}
+
+// flushStatusEvents waits for the publisher to empty the buffer, at most until deadline
+func (r *Rabbit) flushStatusEvents(deadline time.Time) {
+	select {
+	case <-r.statusDone:
+	case <-time.After(time.Until(deadline)):
+		log.Printf("Unpublished MatchStatusChangedEvents dropped: %d", len(r.statusEvents))
+	}
+}
@@ -0,0 +1,53 @@
+package rcon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// defaultAllowedCommands covers pausing, kicking, talking and looking at the server. Cvars are
+// allowed by adding their names to RCON_ALLOWED_COMMANDS.
+const defaultAllowedCommands = "status,pause,unpause,kick,kickid,say,users"
+
+var ErrCommandNotAllowed = errors.New("rcon command is not allowed")
+
+// AllowList holds lower-cased command names; the arguments aren't checked
+type AllowList map[string]bool
+
+func NewAllowList(spec string) AllowList {
+	allowed := AllowList{}
+	for _, name := range strings.Split(spec, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			allowed[name] = true
+		}
+	}
+	return allowed
+}
+
+// CurrentAllowList reads RCON_ALLOWED_COMMANDS, a comma-separated list of command and cvar names
+func CurrentAllowList() AllowList {
+	spec := os.Getenv("RCON_ALLOWED_COMMANDS")
+	if spec == "" {
+		spec = defaultAllowedCommands
+	}
+	return NewAllowList(spec)
+}
+
+// Check rejects empty and chained commands and those whose name isn't listed
+func (a AllowList) Check(command string) error {
+	// srcds splits on ';' and newlines, which would smuggle in a second command
+	if strings.ContainsAny(command, ";\n\r") {
+		return fmt.Errorf("%w: only one command at a time", ErrCommandNotAllowed)
+	}
+
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return fmt.Errorf("%w: empty command", ErrCommandNotAllowed)
+	}
+	if !a[strings.ToLower(fields[0])] {
+		return fmt.Errorf("%w: %s", ErrCommandNotAllowed, fields[0])
+	}
+	return nil
+}
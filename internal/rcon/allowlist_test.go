@@ -0,0 +1,35 @@
+package rcon
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAllowListCheck(t *testing.T) {
+	allowed := NewAllowList(" status, Kick ,sv_cheats,")
+
+	cases := []struct {
+		command string
+		ok      bool
+	}{
+		{"status", true},
+		{"KICK player", true},
+		{"sv_cheats 0", true},
+		{"  status  ", true},
+		{"rcon_password x", false},
+		{"", false},
+		{"say hi", false},
+		{"status; rcon_password x", false},
+		{"status\nquit", false},
+	}
+
+	for _, c := range cases {
+		err := allowed.Check(c.command)
+		if c.ok && err != nil {
+			t.Errorf("expected %q to be allowed, got %v", c.command, err)
+		}
+		if !c.ok && !errors.Is(err, ErrCommandNotAllowed) {
+			t.Errorf("expected %q to be rejected, got %v", c.command, err)
+		}
+	}
+}
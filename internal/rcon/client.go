@@ -0,0 +1,170 @@
+package rcon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+/**
+Source RCON over TCP, see https://developer.valvesoftware.com/wiki/Source_RCON_Protocol
+A packet is: int32 size, int32 id, int32 type, body, two NUL bytes, all little endian.
+A long response comes in several packets, so every command is followed by an empty RESPONSE_VALUE
+packet: srcds answers it after the whole response, which marks the end.
+*/
+
+const (
+	typeResponseValue = 0
+	typeExecCommand   = 2
+	typeAuthResponse  = 2
+	typeAuth          = 3
+
+	maxPacketSize = 4096 + 10 // body limit of srcds plus header and terminators
+	headerSize    = 8         // id and type, counted by size
+)
+
+var ErrAuthFailed = errors.New("rcon authentication failed")
+
+type packet struct {
+	Id   int32
+	Type int32
+	Body string
+}
+
+type Client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	nextId int32
+}
+
+// Dial connects and authenticates. The deadline of ctx, if any, covers the whole connection.
+func Dial(ctx context.Context, addr, password string) (*Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c := &Client{conn: conn, reader: bufio.NewReader(conn), nextId: 1}
+	if err := c.auth(password); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) auth(password string) error {
+	id := c.id()
+	if err := c.write(packet{Id: id, Type: typeAuth, Body: password}); err != nil {
+		return err
+	}
+
+	// srcds sends an empty RESPONSE_VALUE before the AUTH_RESPONSE
+	for {
+		p, err := c.read()
+		if err != nil {
+			return err
+		}
+		if p.Type != typeAuthResponse {
+			continue
+		}
+		if p.Id == -1 || p.Id != id {
+			return ErrAuthFailed
+		}
+		return nil
+	}
+}
+
+// Execute runs the command and returns its whole output
+func (c *Client) Execute(command string) (string, error) {
+	id := c.id()
+	end := c.id()
+	if err := c.write(packet{Id: id, Type: typeExecCommand, Body: command}); err != nil {
+		return "", err
+	}
+	if err := c.write(packet{Id: end, Type: typeResponseValue}); err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	for {
+		p, err := c.read()
+		if err != nil {
+			return out.String(), err
+		}
+		switch p.Id {
+		case id:
+			out.WriteString(p.Body)
+		case end:
+			return out.String(), nil
+		}
+	}
+}
+
+func (c *Client) id() int32 {
+	id := c.nextId
+	c.nextId++
+	return id
+}
+
+func (c *Client) write(p packet) error {
+	raw, err := encodePacket(p)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Write(raw)
+	return err
+}
+
+func (c *Client) read() (packet, error) {
+	return decodePacket(c.reader)
+}
+
+func encodePacket(p packet) ([]byte, error) {
+	size := headerSize + len(p.Body) + 2
+	if size+4 > maxPacketSize {
+		return nil, fmt.Errorf("rcon packet of %d bytes is too large", size+4)
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, size+4))
+	_ = binary.Write(buf, binary.LittleEndian, int32(size))
+	_ = binary.Write(buf, binary.LittleEndian, p.Id)
+	_ = binary.Write(buf, binary.LittleEndian, p.Type)
+	buf.WriteString(p.Body)
+	buf.Write([]byte{0, 0})
+	return buf.Bytes(), nil
+}
+
+func decodePacket(r io.Reader) (packet, error) {
+	var size int32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return packet{}, err
+	}
+	if size < headerSize+2 || size+4 > maxPacketSize {
+		return packet{}, fmt.Errorf("invalid rcon packet size %d", size)
+	}
+
+	raw := make([]byte, size)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return packet{}, err
+	}
+
+	return packet{
+		Id:   int32(binary.LittleEndian.Uint32(raw[0:4])),
+		Type: int32(binary.LittleEndian.Uint32(raw[4:8])),
+		Body: string(bytes.TrimRight(raw[headerSize:], "\x00")),
+	}, nil
+}
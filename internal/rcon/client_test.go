@@ -0,0 +1,103 @@
+package rcon
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer answers like srcds: the empty packet before the auth response, and a command output
+// split in two packets before the mirrored end marker
+func fakeServer(t *testing.T, password string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFake(conn, password)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveFake(conn net.Conn, password string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(p packet) {
+		raw, _ := encodePacket(p)
+		_, _ = conn.Write(raw)
+	}
+
+	for {
+		p, err := decodePacket(r)
+		if err != nil {
+			return
+		}
+		switch p.Type {
+		case typeAuth:
+			reply(packet{Id: p.Id, Type: typeResponseValue})
+			if p.Body != password {
+				reply(packet{Id: -1, Type: typeAuthResponse})
+				return
+			}
+			reply(packet{Id: p.Id, Type: typeAuthResponse})
+		case typeExecCommand:
+			output := "output of " + p.Body
+			reply(packet{Id: p.Id, Type: typeResponseValue, Body: output[:5]})
+			reply(packet{Id: p.Id, Type: typeResponseValue, Body: output[5:]})
+		case typeResponseValue:
+			reply(packet{Id: p.Id, Type: typeResponseValue})
+		}
+	}
+}
+
+func TestClientExecute(t *testing.T) {
+	addr := fakeServer(t, "secret")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	client, err := Dial(ctx, addr, "secret")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer client.Close()
+
+	for _, command := range []string{"status", "pause"} {
+		out, err := client.Execute(command)
+		if err != nil {
+			t.Fatalf("execute %q failed: %v", command, err)
+		}
+		if out != "output of "+command {
+			t.Errorf("expected the joined output of %q, got %q", command, out)
+		}
+	}
+}
+
+func TestClientWrongPassword(t *testing.T) {
+	addr := fakeServer(t, "secret")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := Dial(ctx, addr, "wrong")
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("expected ErrAuthFailed, got %v", err)
+	}
+}
+
+func TestEncodeTooLarge(t *testing.T) {
+	if _, err := encodePacket(packet{Body: strings.Repeat("x", maxPacketSize)}); err == nil {
+		t.Error("expected an oversized packet to be refused")
+	}
+}
@@ -0,0 +1,98 @@
+package rcon
+
+import (
+	"context"
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/k8s"
+	"d2c-gs-controller/internal/metrics"
+	"d2c-gs-controller/internal/util"
+	"errors"
+	"fmt"
+	"log"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	SourceAdmin = "admin"
+	SourceRedis = "redis"
+
+	passwordKey = "RCON_PASSWORD"
+)
+
+// ErrNotReachable means the match has no scheduled pod to talk to yet
+var ErrNotReachable = errors.New("match is not scheduled yet")
+
+type CommandRequest struct {
+	MatchID int64  `json:"matchId"`
+	Command string `json:"command"`
+	Actor   string `json:"actor"` // who asked, for the audit log
+}
+
+type CommandResponse struct {
+	MatchID  int64  `json:"matchId"`
+	Command  string `json:"command"`
+	Response string `json:"response"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Execute checks the command against the allow-list, sends it to the match and records it in rcon_audit.
+// Rejected commands are recorded too. The password is read from the Secret of the match.
+func Execute(ctx context.Context, source string, req *CommandRequest) (string, error) {
+	audit := db.RconAudit{MatchId: req.MatchID, Actor: req.Actor, Source: source, Command: req.Command}
+
+	if err := CurrentAllowList().Check(req.Command); err != nil {
+		audit.Error = err.Error()
+		record(audit, "rejected")
+		return "", err
+	}
+	audit.Allowed = true
+
+	response, err := send(ctx, req.MatchID, req.Command)
+	audit.Response = response
+	if err != nil {
+		audit.Error = err.Error()
+		record(audit, "failed")
+		return response, err
+	}
+	record(audit, "ok")
+	return response, nil
+}
+
+func send(ctx context.Context, matchId int64, command string) (string, error) {
+	mr, err := db.FindMatchResources(matchId)
+	if err != nil {
+		return "", err
+	}
+	if !mr.Endpoint.Known() {
+		return "", ErrNotReachable
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, util.GetEnvDuration("RCON_TIMEOUT", "5s"))
+	defer cancel()
+
+	secret, err := k8s.GetClient().CoreV1().Secrets(k8s.Namespace).Get(ctx, mr.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("reading rcon password: %w", err)
+	}
+	password := string(secret.Data[passwordKey])
+	if password == "" {
+		return "", fmt.Errorf("secret %s has no %s", mr.SecretName, passwordKey)
+	}
+
+	client, err := Dial(ctx, mr.Endpoint.ConnectString(), password)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	return client.Execute(command)
+}
+
+func record(audit db.RconAudit, result string) {
+	log.Printf("[RCON] %s via %s on match %d: %q (%s)", audit.Actor, audit.Source, audit.MatchId, audit.Command, result)
+	metrics.RconCommands.WithLabelValues(audit.Source, result).Inc()
+	if err := db.RecordRconCommand(audit); err != nil {
+		log.Printf("[RCON] Failed to record audit of match %d: %v", audit.MatchId, err)
+	}
+}
@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"context"
+)
+
+const (
+	// heartbeatStateKey keeps the last published status per server url, so a new leader doesn't repeat events
+	heartbeatStateKey = "d2c-gs-controller:heartbeat-state"
+
+	scanBatch = 100
+)
+
+// ScanValues returns the values of every key matching pattern. It walks the keyspace with SCAN and
+// reads in batches, so Redis is never blocked the way KEYS blocks it. Keys that expire meanwhile are left out.
+func ScanValues(ctx context.Context, pattern string) (map[string]string, error) {
+	values := map[string]string{}
+
+	var batch []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		res, err := Client.MGet(ctx, batch...).Result()
+		if err != nil {
+			return err
+		}
+		for i, v := range res {
+			if s, ok := v.(string); ok {
+				values[batch[i]] = s
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	iter := Client.Scan(ctx, 0, pattern, scanBatch).Iterator()
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == scanBatch {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// HeartbeatStates returns the last published status of every server url
+func HeartbeatStates(ctx context.Context) (map[string]bool, error) {
+	raw, err := Client.HGetAll(ctx, heartbeatStateKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]bool, len(raw))
+	for url, state := range raw {
+		states[url] = state == "alive"
+	}
+	return states, nil
+}
+
+func SetHeartbeatState(ctx context.Context, url string, alive bool) error {
+	state := "dead"
+	if alive {
+		state = "alive"
+	}
+	return Client.HSet(ctx, heartbeatStateKey, url, state).Err()
+}
+
+func ForgetHeartbeatState(ctx context.Context, url string) error {
+	return Client.HDel(ctx, heartbeatStateKey, url).Err()
+}
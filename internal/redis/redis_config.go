@@ -42,6 +42,14 @@ func InitRedisClient() {
 	log.Println("Redis Client initialized")
 }
 
+func Close() {
+	if err := Client.Close(); err != nil {
+		log.Printf("Failed to close Redis client: %v", err)
+		return
+	}
+	log.Println("Redis client closed")
+}
+
 // publishWithRetry publishes a message with automatic retry logic.
 func publishWithRetry[T any](channel string, event *T, retries int) error {
 	var err error
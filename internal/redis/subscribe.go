@@ -1,18 +1,83 @@
 package redis
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"time"
 )
 
+// requestClaimPrefix keys the claims of SubscribeOnce, one per channel and request id
+const requestClaimPrefix = "d2c-gs-controller:request"
+
 type redisRequest[T any] struct {
 	Id      string `json:"id"`
 	Data    T      `json:"data"`
 	Pattern string `json:"pattern"`
 }
 
-func Subscribe[In any, Out any](channel string, handler func(msg *In) (*Out, error)) {
+// Subscribe handles requests on channel until ctx is done. Every replica subscribed gets every request.
+func Subscribe[In any, Out any](ctx context.Context, channel string, handler func(msg *In) (*Out, error)) {
+	subscribe(ctx, channel, func(event *redisRequest[In]) {
+		handle(channel, event, handler)
+	})
+}
+
+// SubscribeOnce handles every request on one replica only: the replica that claims the request id first.
+// Requests run concurrently, so a slow one doesn't hold up the rest. The claim is kept for claimTTL.
+func SubscribeOnce[In any, Out any](ctx context.Context, channel string, claimTTL time.Duration, handler func(msg *In) (*Out, error)) {
+	subscribe(ctx, channel, func(event *redisRequest[In]) {
+		claimed, err := claim(ctx, channel, event.Id, claimTTL)
+		if err != nil {
+			log.Printf("[RedisSubscribe] Failed to claim request %s on %s: %v", event.Id, channel, err)
+			return
+		}
+		if !claimed {
+			return // another replica handles it
+		}
+		go handle(channel, event, handler)
+	})
+}
+
+func claim(ctx context.Context, channel, id string, ttl time.Duration) (bool, error) {
+	if id == "" {
+		return false, errors.New("request has no id")
+	}
+	return Client.SetNX(ctx, fmt.Sprintf("%s:%s:%s", requestClaimPrefix, channel, id), "1", ttl).Result()
+}
+
+func handle[In any, Out any](channel string, event *redisRequest[In], handler func(msg *In) (*Out, error)) {
+	res, err := handler(&event.Data)
+	if err != nil {
+		log.Printf("[RedisSubscribe] Handler error: %v", err)
+		return
+	}
+
+	if res == nil {
+		return // no reply
+	}
+
+	replyChannel := channel + ".reply"
+
+	response := redisRequest[Out]{
+		Id:      event.Id,
+		Data:    *res,
+		Pattern: event.Pattern,
+	}
+
+	bt, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("[RedisSubscribe] Failed to encode reply on %s: %v", channel, err)
+		return
+	}
+
+	log.Printf("[RedisSubscribe] Publishing message to %s %v", channel, response)
+	Client.Publish(ctx, replyChannel, bt)
+}
+
+func subscribe[In any](ctx context.Context, channel string, dispatch func(event *redisRequest[In])) {
 	backoff := time.Second
 
 	for {
@@ -38,31 +103,7 @@ func Subscribe[In any, Out any](channel string, handler func(msg *In) (*Out, err
 					continue
 				}
 
-				res, err := handler(&event.Data)
-				if err != nil {
-					log.Printf("[RedisSubscribe] Handler error: %v", err)
-					continue
-				}
-
-				if res == nil {
-					continue // no reply
-				}
-
-				// you could publish reply here if needed
-				// e.g. Client.Publish(ctx, event.ReplyTo, json.Marshal(res))
-
-				replyChannel := channel + ".reply"
-
-				response := redisRequest[Out]{
-					Id:      event.Id,
-					Data:    *res,
-					Pattern: event.Pattern,
-				}
-
-				bt, err := json.Marshal(response)
-
-				log.Printf("[RedisSubscribe] Publishing message to %s %v", channel, response)
-				Client.Publish(ctx, replyChannel, bt)
+				dispatch(&event)
 
 			case <-ctx.Done():
 				log.Printf("[RedisSubscribe] Context canceled for %s, exiting...", channel)
@@ -74,7 +115,11 @@ func Subscribe[In any, Out any](channel string, handler func(msg *In) (*Out, err
 	reconnect:
 		_ = sub.Close()
 		log.Printf("[RedisSubscribe] Reconnecting to %s in %v...", channel, backoff)
-		time.Sleep(backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
 
 		// Exponential backoff up to 30s
 		if backoff < 30*time.Second {
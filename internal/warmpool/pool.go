@@ -0,0 +1,82 @@
+package warmpool
+
+import (
+	"context"
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/k8s"
+	"d2c-gs-controller/internal/metrics"
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/dota2classic/d2c-go-models/models"
+)
+
+/**
+Warm pool: idle gameserver pods kept per region and patch (warm_pool_settings), with image pulled and ports bound.
+- the leader refills the pool in the background and retires servers that died or run a stale image
+- a launch claims the oldest ready server of its region and image and sends the match config to its sidecar
+- launches that need cpu affinity or their own resources, or find the pool empty, start cold as before
+*/
+
+var ErrNoWarmServer = errors.New("no warm server available")
+
+// IsWarmJob tells jobs started by the pool from jobs started for a match
+func IsWarmJob(jobName string) bool {
+	return strings.HasPrefix(jobName, "gameserver-warm-")
+}
+
+// Enabled reports whether WARM_POOL_ENABLED turns the pool on
+func Enabled() bool {
+	return os.Getenv("WARM_POOL_ENABLED") == "true"
+}
+
+// Claim deploys the match on a warm server. The match_resources row is left in provisioning.
+// Anything that goes wrong after the claim is rolled back and the warm server is thrown away.
+func Claim(ctx context.Context, evt *models.LaunchGameServerCommand) (*k8s.DeployedMatch, error) {
+	settings, err := db.ResolveSettings(evt.LobbyType, evt.Region)
+	if err != nil {
+		return nil, err
+	}
+	// Pool pods are never pinned to cpus and run on the default resources
+	if settings.CpuAffinity || settings.QosClass != "" ||
+		settings.Resources != (db.ContainerResources{}) || settings.SidecarResources != (db.ContainerResources{}) {
+		return nil, ErrNoWarmServer
+	}
+
+	image, err := k8s.ResolveGameServerImage(evt, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	ws, err := db.ClaimWarmServer(evt.Region, image, evt.MatchID)
+	if errors.Is(err, sql.ErrNoRows) {
+		metrics.WarmClaims.WithLabelValues(string(evt.Region), "miss").Inc()
+		return nil, ErrNoWarmServer
+	}
+	if err != nil {
+		metrics.WarmClaims.WithLabelValues(string(evt.Region), "error").Inc()
+		return nil, err
+	}
+
+	log.Printf("Match %d claimed warm server %d", evt.MatchID, ws.Id)
+	deployed := &k8s.DeployedMatch{
+		ConfigMapName: ws.ConfigMapName,
+		SecretName:    ws.SecretName,
+		JobName:       ws.JobName,
+	}
+
+	client := k8s.GetClient()
+	if err := k8s.AssignWarmServer(ctx, client, ws, evt, settings); err != nil {
+		metrics.WarmClaims.WithLabelValues(string(evt.Region), "error").Inc()
+		k8s.RollbackDeployment(client, evt.MatchID, deployed)
+		db.ReleasePorts(evt.MatchID)
+		db.DeleteMatchResources(evt.MatchID)
+		return nil, err
+	}
+
+	metrics.WarmClaims.WithLabelValues(string(evt.Region), "hit").Inc()
+	return deployed, nil
+}
@@ -0,0 +1,272 @@
+package warmpool
+
+import (
+	"context"
+	"d2c-gs-controller/internal/db"
+	"d2c-gs-controller/internal/k8s"
+	"d2c-gs-controller/internal/metrics"
+	"d2c-gs-controller/internal/util"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// maxStartsPerCycle keeps a freshly enabled pool from flooding the scheduler
+	maxStartsPerCycle = 2
+
+	// leftoverGrace protects jobs that are still being created or handed to a match
+	leftoverGrace = time.Minute
+)
+
+type poolKey struct {
+	Region models.Region
+	Patch  models.DotaPatch
+}
+
+// CronRefill keeps every pool at its configured size. It runs on the leader only.
+func CronRefill(ctx context.Context) {
+	if !Enabled() {
+		return
+	}
+
+	interval := util.GetEnvDuration("WARM_POOL_REFILL_INTERVAL", "15s")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := refill(ctx)
+			if err != nil {
+				log.Printf("Warm pool refill error: %v", err)
+			}
+		}
+	}
+}
+
+func refill(ctx context.Context) error {
+	settings, err := db.FindWarmPoolSettings()
+	if err != nil {
+		return err
+	}
+	servers, err := db.FindWarmServers()
+	if err != nil {
+		return err
+	}
+	matches, err := db.FindAllMatchResources()
+	if err != nil {
+		return err
+	}
+
+	client := k8s.GetClient()
+	jobs, err := client.BatchV1().Jobs(k8s.Namespace).List(ctx, metav1.ListOptions{LabelSelector: k8s.WarmServerLabel})
+	if err != nil {
+		return err
+	}
+	pods, err := client.CoreV1().Pods(k8s.Namespace).List(ctx, metav1.ListOptions{LabelSelector: k8s.WarmServerLabel})
+	if err != nil {
+		return err
+	}
+
+	jobsByName := map[string]*batchv1.Job{}
+	for i := range jobs.Items {
+		jobsByName[jobs.Items[i].Name] = &jobs.Items[i]
+	}
+	readyJobs := map[string]bool{}
+	for i := range pods.Items {
+		if k8s.IsPodReady(&pods.Items[i]) {
+			readyJobs[pods.Items[i].Labels["job-name"]] = true
+		}
+	}
+
+	wanted := map[poolKey]int{}
+	images := map[poolKey]string{}
+	for _, s := range settings {
+		key := poolKey{Region: s.Region, Patch: s.Patch}
+		image, err := db.FindImageForPatch(s.Patch)
+		if err != nil || image == "" {
+			log.Printf("Warm pool %s/%s has no image: %v", s.Region, s.Patch, err)
+			continue
+		}
+		wanted[key] = s.Size
+		images[key] = image
+	}
+
+	alive := map[poolKey][]db.WarmServer{}
+	for _, ws := range servers {
+		key := poolKey{Region: ws.Region, Patch: ws.Patch}
+		job := jobsByName[ws.JobName]
+		delete(jobsByName, ws.JobName)
+
+		if reason := retireReason(&ws, job, images[key], readyJobs[ws.JobName]); reason != "" {
+			retire(client, &ws, reason)
+			continue
+		}
+
+		if !ws.Ready && readyJobs[ws.JobName] {
+			if err := db.MarkWarmServerReady(ws.Id); err != nil {
+				log.Printf("Failed to mark warm server %d ready: %v", ws.Id, err)
+			} else {
+				ws.Ready = true
+			}
+		}
+		alive[key] = append(alive[key], ws)
+	}
+
+	deleteLeftoverJobs(ctx, client, jobsByName, matches)
+
+	alive, surplus := shrink(alive, wanted)
+	for i := range surplus {
+		retire(client, &surplus[i], "pool shrunk")
+	}
+
+	for key, count := range startCounts(alive, wanted) {
+		for i := 0; i < count; i++ {
+			ws, err := start(ctx, client, key, images[key])
+			if err != nil {
+				log.Printf("Failed to start warm server for %s/%s: %v", key.Region, key.Patch, err)
+				break
+			}
+			alive[key] = append(alive[key], *ws)
+		}
+	}
+
+	updatePoolMetrics(alive)
+	return nil
+}
+
+// retireReason says why a warm server has to go, or returns "" if it stays
+func retireReason(ws *db.WarmServer, job *batchv1.Job, image string, ready bool) string {
+	switch {
+	case job == nil:
+		return "job is gone"
+	case k8s.IsJobFinished(job):
+		return "job finished"
+	case image == "":
+		return "pool disabled"
+	case ws.Image != image:
+		return fmt.Sprintf("image changed to %s", image)
+	case time.Since(ws.CreatedAt) > util.GetEnvDuration("WARM_POOL_MAX_AGE", "6h"):
+		return "too old"
+	case !ws.Ready && !ready && time.Since(ws.CreatedAt) > util.GetEnvDuration("WARM_POOL_START_TIMEOUT", "10m"):
+		return "never became ready"
+	}
+	return ""
+}
+
+// shrink cuts every pool down to its size and returns the servers that no longer fit, newest first:
+// they are the least likely to be ready
+func shrink(alive map[poolKey][]db.WarmServer, wanted map[poolKey]int) (map[poolKey][]db.WarmServer, []db.WarmServer) {
+	kept := map[poolKey][]db.WarmServer{}
+	var surplus []db.WarmServer
+	for key, members := range alive {
+		for i := len(members) - 1; i >= wanted[key]; i-- {
+			surplus = append(surplus, members[i])
+		}
+		if len(members) > wanted[key] {
+			members = members[:wanted[key]]
+		}
+		kept[key] = members
+	}
+	return kept, surplus
+}
+
+// startCounts says how many servers every pool starts this cycle, at most maxStartsPerCycle each
+func startCounts(alive map[poolKey][]db.WarmServer, wanted map[poolKey]int) map[poolKey]int {
+	counts := map[poolKey]int{}
+	for key, size := range wanted {
+		missing := min(size-len(alive[key]), maxStartsPerCycle)
+		if missing > 0 {
+			counts[key] = missing
+		}
+	}
+	return counts
+}
+
+func start(ctx context.Context, client *kubernetes.Clientset, key poolKey, image string) (*db.WarmServer, error) {
+	ws, err := db.InsertWarmServer(key.Region, key.Patch, image)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := k8s.PlanWarmServer(ws)
+	if err != nil {
+		db.DeleteWarmServer(ws.Id)
+		return nil, err
+	}
+
+	if err := k8s.ApplyMatchPlan(ctx, client, plan); err != nil {
+		k8s.RollbackDeployment(client, plan.MatchId, plan.Names())
+		db.DeleteWarmServer(ws.Id)
+		return nil, err
+	}
+
+	log.Printf("Started warm server %d for %s/%s", ws.Id, key.Region, key.Patch)
+	return ws, nil
+}
+
+// retire removes the pool entry first and its objects only after that: a server claimed meanwhile
+// belongs to a match, whose job must not be deleted.
+func retire(client *kubernetes.Clientset, ws *db.WarmServer, reason string) {
+	removed, err := db.DeleteWarmServer(ws.Id)
+	if err != nil {
+		log.Printf("Failed to delete warm server %d: %v", ws.Id, err)
+		return
+	}
+	if !removed {
+		log.Printf("Warm server %d was claimed before it could be retired (%s)", ws.Id, reason)
+		return
+	}
+
+	log.Printf("Retiring warm server %d (%s/%s): %s", ws.Id, ws.Region, ws.Patch, reason)
+	k8s.RollbackDeployment(client, ws.LeaseId(), &k8s.DeployedMatch{
+		ConfigMapName: ws.ConfigMapName,
+		SecretName:    ws.SecretName,
+		JobName:       ws.JobName,
+	})
+}
+
+// deleteLeftoverJobs removes warm jobs that neither the pool nor a match knows about
+func deleteLeftoverJobs(ctx context.Context, client *kubernetes.Clientset, jobs map[string]*batchv1.Job, matches []db.MatchResources) {
+	claimed := map[string]bool{}
+	for _, mr := range matches {
+		claimed[mr.JobName] = true
+	}
+
+	deletePolicy := metav1.DeletePropagationBackground
+	for name, job := range jobs {
+		if claimed[name] || time.Since(job.CreationTimestamp.Time) < leftoverGrace {
+			continue
+		}
+
+		log.Printf("Deleting leftover warm job %s", name)
+		err := client.BatchV1().Jobs(k8s.Namespace).Delete(ctx, name, metav1.DeleteOptions{
+			PropagationPolicy: &deletePolicy,
+		})
+		if err != nil {
+			log.Printf("Failed to delete leftover warm job %s: %v", name, err)
+		}
+	}
+}
+
+func updatePoolMetrics(alive map[poolKey][]db.WarmServer) {
+	metrics.WarmServers.Reset()
+	for key, members := range alive {
+		ready := 0
+		for _, ws := range members {
+			if ws.Ready {
+				ready++
+			}
+		}
+		metrics.WarmServers.WithLabelValues(string(key.Region), string(key.Patch), "ready").Set(float64(ready))
+		metrics.WarmServers.WithLabelValues(string(key.Region), string(key.Patch), "starting").Set(float64(len(members) - ready))
+	}
+}
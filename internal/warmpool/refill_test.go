@@ -0,0 +1,102 @@
+package warmpool
+
+import (
+	"d2c-gs-controller/internal/db"
+	"testing"
+	"time"
+
+	"github.com/dota2classic/d2c-go-models/models"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRetireReason(t *testing.T) {
+	running := &batchv1.Job{}
+	finished := &batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+		{Type: batchv1.JobFailed, Status: corev1.ConditionTrue},
+	}}}
+	now := time.Now()
+
+	cases := []struct {
+		name   string
+		ws     db.WarmServer
+		job    *batchv1.Job
+		image  string
+		ready  bool
+		reason string
+	}{
+		{"healthy", db.WarmServer{Image: "gs:1", Ready: true, CreatedAt: now}, running, "gs:1", true, ""},
+		{"starting", db.WarmServer{Image: "gs:1", CreatedAt: now.Add(-time.Minute)}, running, "gs:1", false, ""},
+		{"job gone", db.WarmServer{Image: "gs:1", CreatedAt: now}, nil, "gs:1", false, "job is gone"},
+		{"job finished", db.WarmServer{Image: "gs:1", CreatedAt: now}, finished, "gs:1", false, "job finished"},
+		{"pool disabled", db.WarmServer{Image: "gs:1", CreatedAt: now}, running, "", true, "pool disabled"},
+		{"image changed", db.WarmServer{Image: "gs:1", CreatedAt: now}, running, "gs:2", true, "image changed to gs:2"},
+		{"too old", db.WarmServer{Image: "gs:1", Ready: true, CreatedAt: now.Add(-7 * time.Hour)}, running, "gs:1", true, "too old"},
+		{"never ready", db.WarmServer{Image: "gs:1", CreatedAt: now.Add(-11 * time.Minute)}, running, "gs:1", false, "never became ready"},
+		{"ready late", db.WarmServer{Image: "gs:1", CreatedAt: now.Add(-11 * time.Minute)}, running, "gs:1", true, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if reason := retireReason(&c.ws, c.job, c.image, c.ready); reason != c.reason {
+				t.Errorf("expected %q, got %q", c.reason, reason)
+			}
+		})
+	}
+}
+
+func TestShrink(t *testing.T) {
+	moscow := poolKey{Region: models.REGION_RU_MOSCOW, Patch: "681"}
+	frankfurt := poolKey{Region: "eu_frankfurt", Patch: "681"}
+	alive := map[poolKey][]db.WarmServer{
+		moscow:    {{Id: 1}, {Id: 2}, {Id: 3}, {Id: 4}},
+		frankfurt: {{Id: 5}},
+	}
+	wanted := map[poolKey]int{moscow: 2, frankfurt: 3}
+
+	kept, surplus := shrink(alive, wanted)
+
+	if len(kept[moscow]) != 2 || kept[moscow][0].Id != 1 || kept[moscow][1].Id != 2 {
+		t.Errorf("expected the two oldest Moscow servers to stay, got %+v", kept[moscow])
+	}
+	if len(kept[frankfurt]) != 1 {
+		t.Errorf("expected the Frankfurt pool to be left alone, got %+v", kept[frankfurt])
+	}
+	if len(surplus) != 2 || surplus[0].Id != 4 || surplus[1].Id != 3 {
+		t.Errorf("expected servers 4 and 3 to be retired, newest first, got %+v", surplus)
+	}
+}
+
+func TestShrinkDisabledPool(t *testing.T) {
+	key := poolKey{Region: models.REGION_RU_MOSCOW, Patch: "681"}
+	alive := map[poolKey][]db.WarmServer{key: {{Id: 1}, {Id: 2}}}
+
+	kept, surplus := shrink(alive, map[poolKey]int{})
+
+	if len(kept[key]) != 0 || len(surplus) != 2 {
+		t.Errorf("expected a pool without settings to be emptied, kept %+v, retired %+v", kept[key], surplus)
+	}
+}
+
+func TestStartCounts(t *testing.T) {
+	empty := poolKey{Region: models.REGION_RU_MOSCOW, Patch: "681"}
+	short := poolKey{Region: "eu_frankfurt", Patch: "681"}
+	full := poolKey{Region: "ru_ekaterinburg", Patch: "681"}
+	alive := map[poolKey][]db.WarmServer{
+		short: {{Id: 1}, {Id: 2}},
+		full:  {{Id: 3}},
+	}
+	wanted := map[poolKey]int{empty: 5, short: 3, full: 1}
+
+	counts := startCounts(alive, wanted)
+
+	if counts[empty] != maxStartsPerCycle {
+		t.Errorf("expected an empty pool to start %d servers, got %d", maxStartsPerCycle, counts[empty])
+	}
+	if counts[short] != 1 {
+		t.Errorf("expected a pool one short to start 1 server, got %d", counts[short])
+	}
+	if _, ok := counts[full]; ok {
+		t.Errorf("expected a full pool to start nothing, got %d", counts[full])
+	}
+}